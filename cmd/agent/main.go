@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
@@ -10,13 +11,20 @@ import (
 
 	"github.com/faradayfan/remote-process-manager/internal/config"
 	"github.com/faradayfan/remote-process-manager/internal/control"
+	"github.com/faradayfan/remote-process-manager/internal/health"
 	"github.com/faradayfan/remote-process-manager/internal/instances"
 	"github.com/faradayfan/remote-process-manager/internal/manager"
 	"github.com/faradayfan/remote-process-manager/internal/protocol"
+	"github.com/faradayfan/remote-process-manager/internal/schedule"
 	"github.com/faradayfan/remote-process-manager/internal/transport"
 )
 
 func main() {
+	// Re-exec of this binary as init of an instance sandbox
+	if manager.IsSandboxInit(os.Args) {
+		manager.RunSandboxInit()
+	}
+
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
 	// Load agent settings (agent ID + server address)
@@ -36,7 +44,10 @@ func main() {
 		log.Fatalf("[agent] failed to load instances: %v", err)
 	}
 
-	mgr := manager.NewManager()
+	mgr := manager.NewManager("data/run", "data/history", agentCfg.CgroupParent)
+	startQueue, _ := config.ConvertStartQueue(agentCfg.StartQueue) // validated by LoadAgent
+	mgr.SetStartQueue(startQueue)
+	mgr.SetNetworkProbe(health.Probe)
 
 	instSvc := instances.NewService(
 		mgr,
@@ -47,8 +58,30 @@ func main() {
 		"logs",
 	)
 
+	// Re-attach to game servers left running by a previous agent process
+	adopted, err := instSvc.AdoptRunning()
+	if err != nil {
+		log.Printf("[agent] re-adopting running instances: %v", err)
+	}
+	if len(adopted) > 0 {
+		log.Printf("[agent] re-adopted running instances: %v", adopted)
+	}
+
+	// Scheduled tasks run on the agent so they keep working without the command server
+	instSvc.Scheduler = schedule.New(instSvc.RunSchedule)
+	if err := instSvc.LoadSchedules(); err != nil {
+		log.Fatalf("[agent] failed to load schedules: %v", err)
+	}
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go instSvc.Scheduler.Run(bgCtx)
+
 	handler := control.NewHandler(agentCfg.AgentID, instSvc)
 
+	// Autostart doesn't wait for the command server; outcomes are reported
+	// with the next registration
+	go instSvc.Autostart(bgCtx, handler.InstanceListChanged)
+
 	log.Printf("[agent] starting agent_id=%s command_server=%s", agentCfg.AgentID, agentCfg.CommandServerAddr)
 
 	// Graceful shutdown support
@@ -61,7 +94,7 @@ func main() {
 	}()
 
 	<-stopCh
-	log.Printf("[agent] shutting down (note: any running game servers will continue unless you stop them via command)")
+	log.Printf("[agent] shutting down (note: running game servers keep running and are re-adopted on next start)")
 }
 
 func runAgentLoop(agentID string, addr string, handler *control.Handler) {
@@ -94,9 +127,7 @@ func connectAndServe(agentID string, addr string, handler *control.Handler) erro
 	tc := transport.NewConn(c)
 
 	// Send register message first
-	regPayload := protocol.RegisterPayload{
-		Servers: handler.SupportedServers(),
-	}
+	regPayload := handler.RegisterPayload()
 
 	regMsg, err := protocol.NewRegister(agentID, regPayload)
 	if err != nil {
@@ -108,13 +139,11 @@ func connectAndServe(agentID string, addr string, handler *control.Handler) erro
 	}
 
 	sendRegister := func() {
-		regPayload := protocol.RegisterPayload{
-			Servers: handler.SupportedServers(),
-		}
-		regMsg, _ := protocol.NewRegister(agentID, regPayload)
+		regMsg, _ := protocol.NewRegister(agentID, handler.RegisterPayload())
 		_ = tc.Send(regMsg)
 	}
-	handler.OnInstanceListChanged = sendRegister
+	handler.SetConnection(tc.Send, sendRegister)
+	defer handler.CloseStreams()
 
 	log.Printf("[agent] registered with command-server addr=%s servers=%v", addr, regPayload.Servers)
 
@@ -159,16 +188,17 @@ func connectAndServe(agentID string, addr string, handler *control.Handler) erro
 			continue
 		}
 
-		resp, err := handler.Handle(msg)
-		if err != nil {
-			// If handler couldn't even produce a response, we can still try to return one
-			fallback, _ := protocol.NewResponse(agentID, msg.ID, nil, err)
-			_ = tc.Send(fallback)
-			continue
-		}
-
-		if err := tc.Send(resp); err != nil {
-			return err
-		}
+		// Handle requests concurrently: stops and rolling restarts can take
+		// minutes and must not hold up status or console requests
+		go func(msg protocol.Message) {
+			resp, err := handler.Handle(msg)
+			if err != nil {
+				// If handler couldn't even produce a response, we can still try to return one
+				resp, _ = protocol.NewResponse(agentID, msg.ID, nil, err)
+			}
+			if err := tc.Send(resp); err != nil {
+				log.Printf("[agent] failed to send response to %s: %v", msg.Type, err)
+			}
+		}(msg)
 	}
 }
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +25,10 @@ func main() {
 	baseURL := getenvDefault("GAMESVC_URL", "http://127.0.0.1:8080")
 	client := &http.Client{Timeout: 10 * time.Second}
 
+	// Starts, stops and restarts wait for stop chains, dependencies and
+	// health checks; the command server bounds them
+	slowClient := &http.Client{}
+
 	cmd := os.Args[1]
 	args := os.Args[2:]
 
@@ -38,7 +45,7 @@ func main() {
 
 	case "instance-create":
 		if len(args) < 3 {
-			fmt.Println("instance-create requires: <agentID> <name> <template> [key=value ...]")
+			fmt.Println("instance-create requires: <agentID> <name> <template> [key=value ...] [--autostart] [--autostart-delay d] [--autostart-priority n] [--depends-on a,b] [--group g]")
 			os.Exit(2)
 		}
 
@@ -53,6 +60,30 @@ func main() {
 			Enabled:  true,
 			Params:   params,
 		}
+		if v, ok := flagValue(args[3:], "--depends-on"); ok {
+			for _, dep := range strings.Split(v, ",") {
+				if dep = strings.TrimSpace(dep); dep != "" {
+					req.DependsOn = append(req.DependsOn, dep)
+				}
+			}
+		}
+		if v, ok := flagValue(args[3:], "--group"); ok {
+			req.Group = v
+		}
+
+		delay, hasDelay := flagValue(args[3:], "--autostart-delay")
+		prio, hasPrio := flagValue(args[3:], "--autostart-priority")
+		if hasFlag(args[3:], "--autostart") || hasDelay || hasPrio {
+			req.Autostart = &protocol.AutostartSpec{Enabled: true, Delay: delay}
+			if hasPrio {
+				n, err := strconv.Atoi(prio)
+				if err != nil {
+					fmt.Println("--autostart-priority must be a number")
+					os.Exit(2)
+				}
+				req.Autostart.Priority = n
+			}
+		}
 
 		doPOST(client, fmt.Sprintf("%s/agents/%s/instances/create", baseURL, agentID), req)
 
@@ -82,7 +113,7 @@ func main() {
 		}
 		agentID := args[0]
 		instance := args[1]
-		doPOST(client, fmt.Sprintf("%s/agents/%s/servers/%s/start", baseURL, agentID, instance), nil)
+		doPOST(slowClient, fmt.Sprintf("%s/agents/%s/servers/%s/start", baseURL, agentID, instance), nil)
 
 	case "stop":
 		if len(args) != 2 {
@@ -91,7 +122,54 @@ func main() {
 		}
 		agentID := args[0]
 		instance := args[1]
-		doPOST(client, fmt.Sprintf("%s/agents/%s/servers/%s/stop", baseURL, agentID, instance), nil)
+		doPOST(slowClient, fmt.Sprintf("%s/agents/%s/servers/%s/stop", baseURL, agentID, instance), nil)
+
+	case "restart":
+		if len(args) < 1 {
+			fmt.Println("restart requires: <agentID> <instance> | <agentID> --rolling [instance ...] [--timeout s] [--continue-on-error]")
+			os.Exit(2)
+		}
+		agentID := args[0]
+
+		if !hasFlag(args[1:], "--rolling") {
+			if len(args) != 2 {
+				fmt.Println("restart requires: <agentID> <instance> (use --rolling for several)")
+				os.Exit(2)
+			}
+			doPOST(slowClient, fmt.Sprintf("%s/agents/%s/servers/%s/restart", baseURL, agentID, args[1]), nil)
+			break
+		}
+
+		req := protocol.RollingRestartRequest{
+			ContinueOnError: hasFlag(args[1:], "--continue-on-error"),
+		}
+		if v, ok := flagValue(args[1:], "--timeout"); ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil {
+				fmt.Println("--timeout must be a number of seconds")
+				os.Exit(2)
+			}
+			req.TimeoutSeconds = secs
+		}
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "--rolling", "--continue-on-error":
+			case "--timeout":
+				i++
+			default:
+				if !strings.HasPrefix(args[i], "--timeout=") {
+					req.Servers = append(req.Servers, args[i])
+				}
+			}
+		}
+		doPOST(slowClient, fmt.Sprintf("%s/agents/%s/restart", baseURL, agentID), req)
+
+	case "group":
+		if len(args) != 3 || (args[1] != "start" && args[1] != "stop") {
+			fmt.Println("group requires: <agentID> start|stop <group>")
+			os.Exit(2)
+		}
+		doPOST(slowClient, fmt.Sprintf("%s/agents/%s/groups/%s/%s", baseURL, args[0], args[2], args[1]), nil)
 
 	case "status":
 		if len(args) != 2 {
@@ -102,6 +180,133 @@ func main() {
 		instance := args[1]
 		doGET(client, fmt.Sprintf("%s/agents/%s/servers/%s/status", baseURL, agentID, instance))
 
+	case "metrics":
+		if len(args) != 2 {
+			fmt.Println("metrics requires: <agentID> <instance>")
+			os.Exit(2)
+		}
+		agentID := args[0]
+		instance := args[1]
+		doGET(client, fmt.Sprintf("%s/agents/%s/servers/%s/metrics", baseURL, agentID, instance))
+
+	case "logs":
+		if len(args) < 2 {
+			fmt.Println("logs requires: <agentID> <instance> [-f] [--tail N]")
+			os.Exit(2)
+		}
+		agentID := args[0]
+		instance := args[1]
+		follow := hasFlag(args[2:], "-f") || hasFlag(args[2:], "--follow")
+
+		q := url.Values{}
+		if tail, ok := flagValue(args[2:], "--tail"); ok {
+			q.Set("tail", tail)
+		}
+		if follow {
+			q.Set("follow", "true")
+		}
+
+		u := fmt.Sprintf("%s/agents/%s/servers/%s/logs", baseURL, agentID, instance)
+		if len(q) > 0 {
+			u += "?" + q.Encode()
+		}
+
+		// Following has no overall deadline; it ends on Ctrl-C or when the agent ends the stream
+		streamClient := client
+		if follow {
+			streamClient = &http.Client{}
+		}
+		doStream(streamClient, u)
+
+	case "console":
+		if len(args) < 3 {
+			fmt.Println("console requires: <agentID> <instance> <command> [--wait ms]")
+			os.Exit(2)
+		}
+		agentID := args[0]
+		instance := args[1]
+
+		req := protocol.ConsoleRequest{Command: args[2]}
+		if v, ok := flagValue(args[3:], "--wait"); ok {
+			ms, err := strconv.Atoi(v)
+			if err != nil {
+				fmt.Println("--wait must be a number of milliseconds")
+				os.Exit(2)
+			}
+			req.WaitMS = ms
+		}
+
+		res := sendConsole(client, fmt.Sprintf("%s/agents/%s/servers/%s/console", baseURL, agentID, instance), req)
+		for _, line := range res.Lines {
+			fmt.Println(line)
+		}
+
+	case "history":
+		if len(args) < 2 {
+			fmt.Println("history requires: <agentID> <instance> [--limit N]")
+			os.Exit(2)
+		}
+		u := fmt.Sprintf("%s/agents/%s/instances/%s/history", baseURL, args[0], args[1])
+		if v, ok := flagValue(args[2:], "--limit"); ok {
+			u += "?" + url.Values{"limit": {v}}.Encode()
+		}
+		doGET(client, u)
+
+	case "schedules":
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Println("schedules requires: <agentID> [instance]")
+			os.Exit(2)
+		}
+		u := fmt.Sprintf("%s/agents/%s/schedules", baseURL, args[0])
+		if len(args) == 2 {
+			u += "?" + url.Values{"instance": {args[1]}}.Encode()
+		}
+		doGET(client, u)
+
+	case "templates":
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Println("templates requires: <agentID> [template]")
+			os.Exit(2)
+		}
+		u := fmt.Sprintf("%s/agents/%s/templates", baseURL, args[0])
+		if len(args) == 2 {
+			u += "/" + url.PathEscape(args[1])
+		}
+		doGET(client, u)
+
+	case "cordon", "drain":
+		if len(args) < 1 {
+			if cmd == "drain" {
+				fmt.Println("drain requires: <agentID> [--reason text] [--wait]")
+			} else {
+				fmt.Println("cordon requires: <agentID> [--reason text]")
+			}
+			os.Exit(2)
+		}
+		reason, _ := flagValue(args[1:], "--reason")
+		doPOST(client, fmt.Sprintf("%s/agents/%s/%s", baseURL, args[0], cmd), protocol.CordonRequest{Reason: reason})
+		if cmd == "drain" && hasFlag(args[1:], "--wait") {
+			waitDrain(client, fmt.Sprintf("%s/agents/%s/maintenance", baseURL, args[0]))
+		}
+
+	case "uncordon", "maintenance":
+		if len(args) != 1 {
+			fmt.Printf("%s requires: <agentID>\n", cmd)
+			os.Exit(2)
+		}
+		if cmd == "uncordon" {
+			doPOST(client, fmt.Sprintf("%s/agents/%s/uncordon", baseURL, args[0]), nil)
+		} else {
+			doGET(client, fmt.Sprintf("%s/agents/%s/maintenance", baseURL, args[0]))
+		}
+
+	case "attach":
+		if len(args) != 2 {
+			fmt.Println("attach requires: <agentID> <instance>")
+			os.Exit(2)
+		}
+		attach(client, baseURL, args[0], args[1])
+
 	default:
 		fmt.Printf("unknown command: %s\n", cmd)
 		usage()
@@ -114,13 +319,32 @@ func usage() {
 Usage:
   gamesvcctl agents
   gamesvcctl instances <agentID>
+  gamesvcctl templates <agentID> [template]
 
   gamesvcctl instance-create <agentID> <name> <template> [key=value ...]
+                             [--autostart] [--autostart-delay d] [--autostart-priority n]
+                             [--depends-on a,b] [--group g]
   gamesvcctl instance-delete <agentID> <name> [--force] [--delete-data]
 
   gamesvcctl start  <agentID> <instance>
   gamesvcctl stop   <agentID> <instance>
+  gamesvcctl restart <agentID> <instance>
+  gamesvcctl restart <agentID> --rolling [instance ...] [--timeout s] [--continue-on-error]
+  gamesvcctl group   <agentID> start|stop <group>
   gamesvcctl status <agentID> <instance>
+  gamesvcctl metrics <agentID> <instance>
+  gamesvcctl logs   <agentID> <instance> [-f] [--tail N]
+  gamesvcctl history <agentID> <instance> [--limit N]
+
+  gamesvcctl console <agentID> <instance> <command> [--wait ms]
+  gamesvcctl attach  <agentID> <instance>
+
+  gamesvcctl schedules <agentID> [instance]
+
+  gamesvcctl cordon   <agentID> [--reason text]
+  gamesvcctl drain    <agentID> [--reason text] [--wait]
+  gamesvcctl uncordon <agentID>
+  gamesvcctl maintenance <agentID>
 
 Environment:
   GAMESVC_URL=http://127.0.0.1:8080
@@ -183,6 +407,121 @@ func doPOST(client *http.Client, url string, payload any) {
 	}
 }
 
+func sendConsole(client *http.Client, url string, req protocol.ConsoleRequest) protocol.ConsoleResponse {
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatal(err)
+	}
+
+	res, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode >= 400 {
+		fmt.Printf("%s\n", prettyJSON(body))
+		os.Exit(1)
+	}
+
+	var out protocol.ConsoleResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		fatal(fmt.Errorf("invalid console response: %w", err))
+	}
+	return out
+}
+
+// attach follows the instance log while sending every line typed on stdin to
+// the instance console, until stdin is closed (Ctrl-D).
+func attach(client *http.Client, baseURL, agentID, instance string) {
+	logsURL := fmt.Sprintf("%s/agents/%s/servers/%s/logs?follow=true&tail=20", baseURL, agentID, instance)
+	consoleURL := fmt.Sprintf("%s/agents/%s/servers/%s/console", baseURL, agentID, instance)
+
+	go func() {
+		doStream(&http.Client{}, logsURL)
+		fmt.Println("[ctl] log stream ended")
+		os.Exit(0)
+	}()
+
+	fmt.Printf("[ctl] attached to %s/%s (Ctrl-D to detach)\n", agentID, instance)
+
+	// Output arrives through the log stream, so don't wait for it here
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sendConsole(client, consoleURL, protocol.ConsoleRequest{Command: line, WaitMS: -1})
+	}
+}
+
+// waitDrain polls the maintenance status until the drain has finished,
+// printing every instance as it changes. It exits non-zero if any stop
+// failed.
+func waitDrain(client *http.Client, url string) {
+	seen := map[string]string{}
+	for {
+		res, err := client.Get(url)
+		if err != nil {
+			fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode >= 400 {
+			fmt.Printf("%s\n", prettyJSON(body))
+			os.Exit(1)
+		}
+
+		var st protocol.MaintenanceStatus
+		if err := json.Unmarshal(body, &st); err != nil {
+			fatal(fmt.Errorf("invalid maintenance response: %w", err))
+		}
+		if st.Drain == nil {
+			fatal(fmt.Errorf("no drain in progress"))
+		}
+		for _, r := range st.Drain.Results {
+			if seen[r.Instance] == r.Outcome {
+				continue
+			}
+			seen[r.Instance] = r.Outcome
+			if r.Error != "" {
+				fmt.Printf("[ctl] %s: %s: %s\n", r.Instance, r.Outcome, r.Error)
+			} else {
+				fmt.Printf("[ctl] %s: %s\n", r.Instance, r.Outcome)
+			}
+		}
+		if st.Drain.Done {
+			fmt.Printf("[ctl] drain finished: %d stopped, %d failed\n", st.Drain.Stopped, st.Drain.Failed)
+			if st.Drain.Failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// doStream copies a text/plain response to stdout as it arrives.
+func doStream(client *http.Client, url string) {
+	res, err := client.Get(url)
+	if err != nil {
+		fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		body, _ := io.ReadAll(res.Body)
+		fmt.Printf("%s\n", prettyJSON(body))
+		os.Exit(1)
+	}
+
+	if _, err := io.Copy(os.Stdout, res.Body); err != nil {
+		fatal(err)
+	}
+}
+
 func prettyJSON(b []byte) string {
 	var v any
 	if err := json.Unmarshal(b, &v); err != nil {
@@ -198,6 +537,9 @@ func prettyJSON(b []byte) string {
 func parseKeyValues(args []string) map[string]string {
 	out := map[string]string{}
 	for _, a := range args {
+		if strings.HasPrefix(a, "--") {
+			continue // flags, e.g. --autostart-delay=30s
+		}
 		parts := strings.SplitN(a, "=", 2)
 		if len(parts) != 2 {
 			continue
@@ -220,6 +562,20 @@ func hasFlag(args []string, flag string) bool {
 	return false
 }
 
+// flagValue returns the value following flag (e.g. "--tail 50") or given
+// inline (e.g. "--tail=50").
+func flagValue(args []string, flag string) (string, bool) {
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if strings.HasPrefix(a, flag+"=") {
+			return strings.TrimPrefix(a, flag+"="), true
+		}
+	}
+	return "", false
+}
+
 func fatal(err error) {
 	fmt.Printf("error: %v\n", err)
 	os.Exit(1)
@@ -55,7 +55,7 @@ func (s *Server) handleStartServer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logPath := s.logPathFor(name)
-	st, err := s.mgr.Start(cfg, logPath)
+	st, err := s.mgr.Start(cfg, logPath, manager.TriggerAPI)
 	if err != nil {
 		// if already running, return current state + conflict
 		cur := s.mgr.Status(name)
@@ -78,7 +78,7 @@ func (s *Server) handleStopServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	st, err := s.mgr.Stop(name)
+	st, err := s.mgr.Stop(name, manager.TriggerAPI)
 	if err != nil {
 		// If it's already stopped, return the current state but as 409-ish info
 		cur := s.mgr.Status(name)
@@ -87,6 +87,7 @@ func (s *Server) wrapJSON(next http.Handler) http.Handler {
 func stateToResponse(st manager.ServerState, logPath string) StartStopResponse {
 	return StartStopResponse{
 		Server:    st.Name,
+		State:     string(st.State),
 		Running:   st.Running,
 		PID:       st.PID,
 		StartedAt: st.StartedAt,
@@ -8,6 +8,7 @@ type ErrorResponse struct {
 
 type StartStopResponse struct {
 	Server    string    `json:"server"`
+	State     string    `json:"state"`
 	Running   bool      `json:"running"`
 	PID       int       `json:"pid"`
 	StartedAt time.Time `json:"started_at,omitempty"`
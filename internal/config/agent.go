@@ -3,13 +3,49 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
 )
 
 type AgentConfig struct {
 	AgentID           string `yaml:"agent_id"`
 	CommandServerAddr string `yaml:"command_server_addr"`
+
+	// CgroupParent is the cgroup v2 directory (delegated to the agent) under
+	// which instances with template resources get their own cgroup.
+	CgroupParent string `yaml:"cgroup_parent"`
+
+	// StartQueue limits how many instances start at the same time.
+	StartQueue StartQueue `yaml:"start_queue"`
+}
+
+type StartQueue struct {
+	MaxConcurrent int    `yaml:"max_concurrent"` // 0 = unlimited
+	Timeout       string `yaml:"timeout"`        // e.g. "5m": release the slot of a slow starter
+}
+
+// DefaultStartQueueTimeout is how long a starting instance holds its slot
+// when start_queue.timeout is not set.
+const DefaultStartQueueTimeout = 5 * time.Minute
+
+func ConvertStartQueue(q StartQueue) (manager.StartQueue, error) {
+	if q.MaxConcurrent < 0 {
+		return manager.StartQueue{}, fmt.Errorf("invalid start_queue.max_concurrent %d (must be >= 0)", q.MaxConcurrent)
+	}
+
+	out := manager.StartQueue{MaxConcurrent: q.MaxConcurrent, Timeout: DefaultStartQueueTimeout}
+	if t := strings.TrimSpace(q.Timeout); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d < 0 {
+			return manager.StartQueue{}, fmt.Errorf("invalid start_queue.timeout %q (expected a duration, e.g. 5m)", q.Timeout)
+		}
+		out.Timeout = d
+	}
+	return out, nil
 }
 
 func LoadAgent(path string) (*AgentConfig, error) {
@@ -29,6 +65,9 @@ func LoadAgent(path string) (*AgentConfig, error) {
 	if cfg.CommandServerAddr == "" {
 		return nil, fmt.Errorf("command_server_addr is required")
 	}
+	if _, err := ConvertStartQueue(cfg.StartQueue); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
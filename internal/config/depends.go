@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyOrder returns names plus everything they depend on, directly or
+// not, ordered so every instance comes after its dependencies. Ties keep
+// the order of names (then dependencies by name). It fails on unknown
+// dependencies and on cycles.
+func DependencyOrder(instances map[string]Instance, names []string) ([]string, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	mark := map[string]int{}
+	var out []string
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch mark[name] {
+		case done:
+			return nil
+		case visiting:
+			// path holds the chain that led back here
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		inst, ok := instances[name]
+		if !ok {
+			if len(path) == 0 {
+				return fmt.Errorf("unknown instance: %s", name)
+			}
+			return fmt.Errorf("instance %q depends on unknown instance %q", path[len(path)-1], name)
+		}
+
+		mark[name] = visiting
+		path = append(path, name)
+		deps := append([]string{}, inst.DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		mark[name] = done
+		out = append(out, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Dependents returns the instances that depend on name, directly or not,
+// ordered so every instance comes after its dependencies (start order; stop
+// them in reverse).
+func Dependents(instances map[string]Instance, name string) []string {
+	set := map[string]bool{}
+	var collect func(n string)
+	collect = func(n string) {
+		for other, inst := range instances {
+			if set[other] {
+				continue
+			}
+			for _, dep := range inst.DependsOn {
+				if dep == n {
+					set[other] = true
+					collect(other)
+					break
+				}
+			}
+		}
+	}
+	collect(name)
+
+	names := make([]string, 0, len(set))
+	for n := range set {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// The graph was validated on load/create, so this cannot fail
+	order, _ := DependencyOrder(instances, names)
+	out := make([]string, 0, len(names))
+	for _, n := range order {
+		if set[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// GroupMembers returns the instances in group, sorted by name.
+func GroupMembers(instances map[string]Instance, group string) []string {
+	var out []string
+	for name, inst := range instances {
+		if inst.Group == group {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
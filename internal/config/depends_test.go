@@ -0,0 +1,160 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graph builds instances from name -> dependencies.
+func graph(deps map[string][]string) map[string]Instance {
+	out := make(map[string]Instance, len(deps))
+	for name, d := range deps {
+		out[name] = Instance{Template: "t", DependsOn: d}
+	}
+	return out
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "no dependencies keeps the given order",
+			deps:  map[string][]string{"a": nil, "b": nil, "c": nil},
+			names: []string{"c", "a", "b"},
+			want:  []string{"c", "a", "b"},
+		},
+		{
+			name:  "linear",
+			deps:  map[string][]string{"web": {"api"}, "api": {"db"}, "db": nil},
+			names: []string{"web"},
+			want:  []string{"db", "api", "web"},
+		},
+		{
+			name:  "linear, every name given",
+			deps:  map[string][]string{"web": {"api"}, "api": {"db"}, "db": nil},
+			names: []string{"web", "api", "db"},
+			want:  []string{"db", "api", "web"},
+		},
+		{
+			name:  "diamond lists the shared dependency once",
+			deps:  map[string][]string{"top": {"right", "left"}, "left": {"base"}, "right": {"base"}, "base": nil},
+			names: []string{"top"},
+			want:  []string{"base", "left", "right", "top"},
+		},
+		{
+			name:  "unrelated names are left alone",
+			deps:  map[string][]string{"a": {"b"}, "b": nil, "x": nil},
+			names: []string{"x", "a"},
+			want:  []string{"x", "b", "a"},
+		},
+		{
+			name:    "self dependency",
+			deps:    map[string][]string{"a": {"a"}},
+			names:   []string{"a"},
+			wantErr: "dependency cycle: a -> a",
+		},
+		{
+			name:    "cycle",
+			deps:    map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			names:   []string{"a"},
+			wantErr: "dependency cycle: a -> b -> c -> a",
+		},
+		{
+			name:    "cycle below the start",
+			deps:    map[string][]string{"top": {"a"}, "a": {"b"}, "b": {"a"}},
+			names:   []string{"top"},
+			wantErr: "dependency cycle: a -> b -> a",
+		},
+		{
+			name:    "unknown dependency",
+			deps:    map[string][]string{"a": {"ghost"}},
+			names:   []string{"a"},
+			wantErr: `instance "a" depends on unknown instance "ghost"`,
+		},
+		{
+			name:    "unknown instance",
+			deps:    map[string][]string{"a": nil},
+			names:   []string{"ghost"},
+			wantErr: "unknown instance: ghost",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DependencyOrder(graph(tt.deps), tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DependencyOrder = %v, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DependencyOrder: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DependencyOrder = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependents(t *testing.T) {
+	deps := map[string][]string{
+		"db":    nil,
+		"cache": nil,
+		"api":   {"db", "cache"},
+		"web":   {"api"},
+		"admin": {"db"},
+		"other": nil,
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"db", []string{"admin", "api", "web"}},
+		{"cache", []string{"api", "web"}},
+		{"api", []string{"web"}},
+		{"web", []string{}},
+		{"other", []string{}},
+	}
+	for _, tt := range tests {
+		got := Dependents(graph(deps), tt.name)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Dependents(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Map iteration order must not leak into the results.
+func TestDependencyOrderDeterministic(t *testing.T) {
+	deps := map[string][]string{
+		"a": nil, "b": nil, "c": nil, "d": nil, "e": nil,
+		"f": {"e", "d", "c"}, "g": {"b", "a"}, "x": nil, "y": nil,
+	}
+	names := []string{"g", "f", "x", "y"}
+	wantOrder, err := DependencyOrder(graph(deps), names)
+	if err != nil {
+		t.Fatalf("DependencyOrder: %v", err)
+	}
+	wantDependents := Dependents(graph(deps), "a")
+	for i := 0; i < 50; i++ {
+		got, err := DependencyOrder(graph(deps), names)
+		if err != nil {
+			t.Fatalf("DependencyOrder: %v", err)
+		}
+		if !reflect.DeepEqual(got, wantOrder) {
+			t.Fatalf("DependencyOrder = %v, then %v", wantOrder, got)
+		}
+		if got := Dependents(graph(deps), "a"); !reflect.DeepEqual(got, wantDependents) {
+			t.Fatalf("Dependents = %v, then %v", wantDependents, got)
+		}
+	}
+	if want := []string{"a", "b", "g", "c", "d", "e", "f", "x", "y"}; !reflect.DeepEqual(wantOrder, want) {
+		t.Errorf("DependencyOrder = %v, want %v", wantOrder, want)
+	}
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tags that choose how a value in a template combines with the one it
+// inherits. Mappings merge key by key and anything else replaces by default.
+const (
+	tagAppend  = "!append"  // list: inherited items, then these
+	tagPrepend = "!prepend" // list: these, then the inherited items
+	tagReplace = "!replace" // mapping or list: drop the inherited value
+)
+
+// templateSource is the raw templates file: templates may extend one other
+// template and include fragments, partial templates meant for reuse.
+type templateSource struct {
+	Fragments map[string]yaml.Node `yaml:"fragments"`
+	Templates map[string]yaml.Node `yaml:"templates"`
+}
+
+// inheritance resolves extends and include into plain template nodes.
+type inheritance struct {
+	src      templateSource
+	resolved map[string]*yaml.Node // "template:name" or "fragment:name"
+	visiting []string              // names on the current chain, for cycle errors
+	kinds    []string
+}
+
+// resolveTemplates returns every template of src with what it extends and
+// includes merged in: first the extended template, then the fragments in
+// the order listed, then its own settings.
+func resolveTemplates(src templateSource) (map[string]*yaml.Node, error) {
+	in := &inheritance{src: src, resolved: map[string]*yaml.Node{}}
+
+	// Fragments nothing includes are still checked
+	for _, name := range sortedKeys(src.Fragments) {
+		if _, err := in.resolve("fragment", name); err != nil {
+			return nil, err
+		}
+	}
+	out := make(map[string]*yaml.Node, len(src.Templates))
+	for _, name := range sortedKeys(src.Templates) {
+		n, err := in.resolve("template", name)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = stripMergeTags(cloneNode(n))
+	}
+	return out, nil
+}
+
+func (in *inheritance) resolve(kind string, name string) (*yaml.Node, error) {
+	key := kind + ":" + name
+	if n, ok := in.resolved[key]; ok {
+		return n, nil
+	}
+	for i, v := range in.visiting {
+		if v == name && in.kinds[i] == kind {
+			cycle := append(append([]string{}, in.visiting[i:]...), name)
+			if kind == "template" {
+				return nil, fmt.Errorf("template extends cycle: %s", strings.Join(cycle, " -> "))
+			}
+			return nil, fmt.Errorf("fragment include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	set := in.src.Templates
+	if kind == "fragment" {
+		set = in.src.Fragments
+	}
+	raw, ok := set[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s %q", kind, name)
+	}
+	own := &raw
+	if own.Kind == yaml.AliasNode {
+		own = own.Alias
+	}
+	if own.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s %q must be a mapping (line %d)", kind, name, own.Line)
+	}
+
+	in.visiting = append(in.visiting, name)
+	in.kinds = append(in.kinds, kind)
+	defer func() {
+		in.visiting = in.visiting[:len(in.visiting)-1]
+		in.kinds = in.kinds[:len(in.kinds)-1]
+	}()
+
+	own = cloneNode(own)
+	extends, err := takeString(own, "extends")
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", kind, name, err)
+	}
+	includes, err := takeStrings(own, "include")
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", kind, name, err)
+	}
+	if extends != "" && kind == "fragment" {
+		return nil, fmt.Errorf("fragment %q cannot extend a template; use include", name)
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if extends != "" {
+		if _, ok := in.src.Templates[extends]; !ok {
+			return nil, fmt.Errorf("template %q extends unknown template %q", name, extends)
+		}
+		base, err := in.resolve("template", extends)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, base)
+	}
+	for _, f := range includes {
+		if _, ok := in.src.Fragments[f]; !ok {
+			return nil, fmt.Errorf("%s %q includes unknown fragment %q", kind, name, f)
+		}
+		frag, err := in.resolve("fragment", f)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, frag)
+	}
+	merged = mergeNodes(merged, own)
+
+	in.resolved[key] = merged
+	return merged, nil
+}
+
+// mergeNodes lays src over dst and returns the result; neither is modified.
+func mergeNodes(dst *yaml.Node, src *yaml.Node) *yaml.Node {
+	if src.Kind == yaml.AliasNode {
+		src = src.Alias
+	}
+	if dst.Kind == yaml.AliasNode {
+		dst = dst.Alias
+	}
+
+	switch {
+	case src.Tag == tagReplace:
+		return cloneNode(src)
+	case src.Kind == yaml.SequenceNode && dst.Kind == yaml.SequenceNode && src.Tag == tagAppend:
+		out := cloneNode(src)
+		out.Content = append(cloneNode(dst).Content, out.Content...)
+		return out
+	case src.Kind == yaml.SequenceNode && dst.Kind == yaml.SequenceNode && src.Tag == tagPrepend:
+		out := cloneNode(src)
+		out.Content = append(out.Content, cloneNode(dst).Content...)
+		return out
+	case src.Kind == yaml.MappingNode && dst.Kind == yaml.MappingNode:
+		out := cloneNode(dst)
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			k, v := src.Content[i], src.Content[i+1]
+			if j := mappingIndex(out, k.Value); j >= 0 {
+				out.Content[j+1] = mergeNodes(out.Content[j+1], v)
+			} else {
+				out.Content = append(out.Content, cloneNode(k), cloneNode(v))
+			}
+		}
+		return out
+	}
+	return cloneNode(src)
+}
+
+// mappingIndex returns the index of key's key node in mapping m, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// takeString removes key from mapping m and returns its scalar value.
+func takeString(m *yaml.Node, key string) (string, error) {
+	i := mappingIndex(m, key)
+	if i < 0 {
+		return "", nil
+	}
+	v := m.Content[i+1]
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+	if v.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%s must be a name (line %d)", key, v.Line)
+	}
+	return v.Value, nil
+}
+
+// takeStrings removes key from mapping m and returns its value, a name or a
+// list of names.
+func takeStrings(m *yaml.Node, key string) ([]string, error) {
+	i := mappingIndex(m, key)
+	if i < 0 {
+		return nil, nil
+	}
+	v := m.Content[i+1]
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+	if v.Kind == yaml.ScalarNode {
+		return []string{v.Value}, nil
+	}
+	var out []string
+	if err := v.Decode(&out); err != nil {
+		return nil, fmt.Errorf("%s must be a list of names (line %d)", key, v.Line)
+	}
+	return out, nil
+}
+
+// sortedKeys returns the names of m in order, so load errors are stable.
+func sortedKeys(m map[string]yaml.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cloneNode(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Kind == yaml.AliasNode {
+		return cloneNode(n.Alias)
+	}
+	out := *n
+	out.Content = make([]*yaml.Node, len(n.Content))
+	for i, c := range n.Content {
+		out.Content[i] = cloneNode(c)
+	}
+	return &out
+}
+
+// stripMergeTags clears the merge tags so the node decodes like plain YAML.
+func stripMergeTags(n *yaml.Node) *yaml.Node {
+	switch n.Tag {
+	case tagAppend, tagPrepend, tagReplace:
+		n.Tag = ""
+		n.Style &^= yaml.TaggedStyle
+	}
+	for _, c := range n.Content {
+		stripMergeTags(c)
+	}
+	return n
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseSource(t *testing.T, src string) templateSource {
+	t.Helper()
+	var s templateSource
+	if err := yaml.Unmarshal([]byte(src), &s); err != nil {
+		t.Fatalf("parse source: %v", err)
+	}
+	return s
+}
+
+func TestResolveTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		template string
+		want     string
+	}{
+		{
+			name: "mappings merge key by key",
+			src: `
+templates:
+  base:
+    command: java
+    stop: {type: stdin, command: stop, grace_period: 15s}
+  child:
+    extends: base
+    stop: {grace_period: 30s}
+`,
+			template: "child",
+			want:     `{command: java, stop: {type: stdin, command: stop, grace_period: 30s}}`,
+		},
+		{
+			name: "lists and scalars replace by default",
+			src: `
+templates:
+  base:
+    command: java
+    args: [-jar, server.jar]
+  child:
+    extends: base
+    command: javaw
+    args: [-jar, other.jar]
+`,
+			template: "child",
+			want:     `{command: javaw, args: [-jar, other.jar]}`,
+		},
+		{
+			name: "append",
+			src: `
+templates:
+  base: {command: java, env: [A=1, B=2]}
+  child:
+    extends: base
+    env: !append [C=3]
+`,
+			template: "child",
+			want:     `{command: java, env: [A=1, B=2, C=3]}`,
+		},
+		{
+			name: "prepend",
+			src: `
+templates:
+  base: {command: java, args: [-jar, server.jar]}
+  child:
+    extends: base
+    args: !prepend [-XX:+UseG1GC]
+`,
+			template: "child",
+			want:     `{command: java, args: [-XX:+UseG1GC, -jar, server.jar]}`,
+		},
+		{
+			name: "append without an inherited list",
+			src: `
+templates:
+  base: {command: java}
+  child:
+    extends: base
+    env: !append [A=1]
+`,
+			template: "child",
+			want:     `{command: java, env: [A=1]}`,
+		},
+		{
+			name: "replace a mapping",
+			src: `
+templates:
+  base:
+    command: java
+    stop:
+      steps: [{stdin: save-all, wait: 5s}, {stdin: stop, wait: 30s}]
+  child:
+    extends: base
+    stop: !replace {type: signal, signal: SIGINT}
+`,
+			template: "child",
+			want:     `{command: java, stop: {type: signal, signal: SIGINT}}`,
+		},
+		{
+			name: "replace a list",
+			src: `
+templates:
+  base: {command: java, env: [A=1]}
+  child:
+    extends: base
+    env: !replace [B=2]
+`,
+			template: "child",
+			want:     `{command: java, env: [B=2]}`,
+		},
+		{
+			name: "multi-level extends",
+			src: `
+templates:
+  a: {command: a, cwd: /a, env: [A=1], health: {pattern: ready}}
+  b:
+    extends: a
+    cwd: /b
+    env: !append [B=1]
+  c:
+    extends: b
+    env: !append [C=1]
+    health: {interval: 5s}
+`,
+			template: "c",
+			want:     `{command: a, cwd: /b, env: [A=1, B=1, C=1], health: {pattern: ready, interval: 5s}}`,
+		},
+		{
+			name: "extends, then fragments in order, then own settings",
+			src: `
+fragments:
+  one: {cwd: /one, env: !append [ONE=1]}
+  two: {cwd: /two, env: !append [TWO=1]}
+templates:
+  base: {command: base, cwd: /base, env: [BASE=1]}
+  child:
+    extends: base
+    include: [two, one]
+    env: !append [OWN=1]
+`,
+			template: "child",
+			want:     `{command: base, cwd: /one, env: [BASE=1, TWO=1, ONE=1, OWN=1]}`,
+		},
+		{
+			name: "fragments include fragments",
+			src: `
+fragments:
+  inner: {stop: {type: signal, signal: SIGTERM}}
+  outer:
+    include: inner
+    stop: {grace_period: 10s}
+templates:
+  t:
+    include: outer
+    command: run
+`,
+			template: "t",
+			want:     `{command: run, stop: {type: signal, signal: SIGTERM, grace_period: 10s}}`,
+		},
+		{
+			name: "merge tags are dropped without a parent",
+			src: `
+templates:
+  t: {command: run, env: !append [A=1], stop: !replace {type: signal}}
+`,
+			template: "t",
+			want:     `{command: run, env: [A=1], stop: {type: signal}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := resolveTemplates(parseSource(t, tt.src))
+			if err != nil {
+				t.Fatalf("resolveTemplates: %v", err)
+			}
+			n, ok := nodes[tt.template]
+			if !ok {
+				t.Fatalf("template %q not resolved", tt.template)
+			}
+			var got, want any
+			if err := n.Decode(&got); err != nil {
+				t.Fatalf("decode resolved: %v", err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("parse want: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				b, _ := yaml.Marshal(n)
+				t.Errorf("resolved %q =\n%s\nwant %s", tt.template, b, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{
+			name:    "unknown parent",
+			src:     `{templates: {a: {extends: missing, command: run}}}`,
+			wantErr: `template "a" extends unknown template "missing"`,
+		},
+		{
+			name:    "unknown fragment",
+			src:     `{templates: {a: {include: [missing], command: run}}}`,
+			wantErr: `template "a" includes unknown fragment "missing"`,
+		},
+		{
+			name:    "extends itself",
+			src:     `{templates: {a: {extends: a, command: run}}}`,
+			wantErr: "template extends cycle: a -> a",
+		},
+		{
+			name:    "extends cycle",
+			src:     `{templates: {a: {extends: b, command: run}, b: {extends: c}, c: {extends: a}}}`,
+			wantErr: "template extends cycle: a -> b -> c -> a",
+		},
+		{
+			name:    "include cycle",
+			src:     `{fragments: {x: {include: y}, y: {include: x}}, templates: {a: {command: run}}}`,
+			wantErr: "fragment include cycle: x -> y -> x",
+		},
+		{
+			name:    "fragment extends a template",
+			src:     `{fragments: {x: {extends: a}}, templates: {a: {command: run}}}`,
+			wantErr: `fragment "x" cannot extend a template`,
+		},
+		{
+			name:    "template is not a mapping",
+			src:     `{templates: {a: [run]}}`,
+			wantErr: `template "a" must be a mapping`,
+		},
+		{
+			name:    "extends is not a name",
+			src:     `{templates: {a: {extends: [b], command: run}, b: {command: run}}}`,
+			wantErr: "extends must be a name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveTemplates(parseSource(t, tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("resolveTemplates error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Templates that use none of extends, include or the merge tags must come
+// out exactly as written.
+func TestResolveTemplatesWithoutExtendsUnchanged(t *testing.T) {
+	src := `
+templates:
+  minecraft:
+    command: "java"
+    args:
+      - "-Xmx{{.mem_max}}"
+      - "-jar"
+      - "{{.jar_path}}"
+    cwd: "{{.instance_dir}}"
+    env: []
+    stop:
+      type: "stdin"
+      command: "stop\n"
+      grace_period: "15s"
+    health:
+      pattern: 'Done \('
+  valheim:
+    command: ./valheim_server.x86_64
+    args: [-name, "{{.name}}", -port, "{{.port}}"]
+    stop: {type: signal, signal: SIGINT}
+`
+	s := parseSource(t, src)
+	nodes, err := resolveTemplates(s)
+	if err != nil {
+		t.Fatalf("resolveTemplates: %v", err)
+	}
+	for name, raw := range s.Templates {
+		want, err := yaml.Marshal(&raw)
+		if err != nil {
+			t.Fatalf("marshal %q: %v", name, err)
+		}
+		got, err := yaml.Marshal(nodes[name])
+		if err != nil {
+			t.Fatalf("marshal resolved %q: %v", name, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("template %q changed:\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
+
+// LoadTemplates on the shipped templates matches decoding the file directly,
+// as it was loaded before inheritance existed.
+func TestLoadTemplatesShippedFileUnchanged(t *testing.T) {
+	path := filepath.Join("..", "..", "configs", "instance-templates.yaml")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var want TemplateConfig
+	if err := yaml.Unmarshal(b, &want); err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	got, err := LoadTemplates(path)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	if !reflect.DeepEqual(got.Templates, want.Templates) {
+		t.Errorf("LoadTemplates(%s) = %+v, want %+v", path, got.Templates, want.Templates)
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// ConvertHealth expects string fields to be rendered already.
+func ConvertHealth(serverName string, h Health) (manager.HealthCheck, error) {
+	if strings.TrimSpace(h.Type) == "" {
+		return manager.HealthCheck{}, nil
+	}
+
+	// defaults
+	cfg := manager.HealthCheck{
+		Interval:         10 * time.Second,
+		Timeout:          5 * time.Second,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+
+	switch strings.ToLower(strings.TrimSpace(h.Type)) {
+	case "tcp":
+		if strings.TrimSpace(h.Address) == "" {
+			return manager.HealthCheck{}, fmt.Errorf("server %q health.type tcp requires health.address", serverName)
+		}
+		cfg.Type = manager.HealthTCP
+		cfg.Address = strings.TrimSpace(h.Address)
+	case "http":
+		if strings.TrimSpace(h.URL) == "" {
+			return manager.HealthCheck{}, fmt.Errorf("server %q health.type http requires health.url", serverName)
+		}
+		cfg.Type = manager.HealthHTTP
+		cfg.URL = strings.TrimSpace(h.URL)
+	case "exec":
+		if strings.TrimSpace(h.Command) == "" {
+			return manager.HealthCheck{}, fmt.Errorf("server %q health.type exec requires health.command", serverName)
+		}
+		cfg.Type = manager.HealthExec
+		cfg.Command = h.Command
+	case "log":
+		if h.Pattern == "" {
+			return manager.HealthCheck{}, fmt.Errorf("server %q health.type log requires health.pattern", serverName)
+		}
+		re, err := regexp.Compile(h.Pattern)
+		if err != nil {
+			return manager.HealthCheck{}, fmt.Errorf("server %q has invalid health.pattern %q: %w", serverName, h.Pattern, err)
+		}
+		cfg.Type = manager.HealthLog
+		cfg.Pattern = re
+	default:
+		return manager.HealthCheck{}, fmt.Errorf("server %q has invalid health.type %q (expected tcp|http|exec|log)", serverName, h.Type)
+	}
+
+	durations := []struct {
+		field string
+		raw   string
+		dst   *time.Duration
+	}{
+		{"interval", h.Interval, &cfg.Interval},
+		{"timeout", h.Timeout, &cfg.Timeout},
+	}
+	for _, d := range durations {
+		if strings.TrimSpace(d.raw) == "" {
+			continue
+		}
+		v, err := time.ParseDuration(strings.TrimSpace(d.raw))
+		if err != nil || v <= 0 {
+			return manager.HealthCheck{}, fmt.Errorf("server %q has invalid health.%s %q", serverName, d.field, d.raw)
+		}
+		*d.dst = v
+	}
+
+	if h.SuccessThreshold < 0 || h.FailureThreshold < 0 || h.RestartAfter < 0 {
+		return manager.HealthCheck{}, fmt.Errorf("server %q has negative health thresholds", serverName)
+	}
+	if h.SuccessThreshold > 0 {
+		cfg.SuccessThreshold = h.SuccessThreshold
+	}
+	if h.FailureThreshold > 0 {
+		cfg.FailureThreshold = h.FailureThreshold
+	}
+	cfg.RestartAfter = h.RestartAfter
+
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// ConvertHooks expects hook commands to be rendered already.
+func ConvertHooks(serverName string, h Hooks) (manager.Hooks, error) {
+	var out manager.Hooks
+	for _, ev := range []struct {
+		name string
+		in   []Hook
+		out  *[]manager.Hook
+	}{
+		{"pre_start", h.PreStart, &out.PreStart},
+		{"post_start", h.PostStart, &out.PostStart},
+		{"pre_stop", h.PreStop, &out.PreStop},
+		{"post_stop", h.PostStop, &out.PostStop},
+		{"on_crash", h.OnCrash, &out.OnCrash},
+	} {
+		for i, hook := range ev.in {
+			if strings.TrimSpace(hook.Command) == "" {
+				return manager.Hooks{}, fmt.Errorf("server %q hooks.%s[%d] missing command", serverName, ev.name, i)
+			}
+			mh := manager.Hook{Command: hook.Command}
+			if hook.Timeout != "" {
+				d, err := time.ParseDuration(hook.Timeout)
+				if err != nil {
+					return manager.Hooks{}, fmt.Errorf("server %q has invalid hooks.%s[%d].timeout %q: %w", serverName, ev.name, i, hook.Timeout, err)
+				}
+				mh.Timeout = d
+			}
+			*ev.out = append(*ev.out, mh)
+		}
+	}
+	return out, nil
+}
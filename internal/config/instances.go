@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,9 +15,46 @@ type InstanceConfig struct {
 }
 
 type Instance struct {
-	Template string            `yaml:"template"`
-	Enabled  bool              `yaml:"enabled"`
-	Params   map[string]string `yaml:"params"`
+	Template  string            `yaml:"template"`
+	Enabled   bool              `yaml:"enabled"`
+	Params    map[string]string `yaml:"params"`
+	Schedules []Schedule        `yaml:"schedules,omitempty"`
+	Autostart Autostart         `yaml:"autostart,omitempty"`
+
+	// DependsOn names instances on this agent that must be running (and
+	// ready) before this one starts; they stop after it.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// Group lets related instances be started and stopped together.
+	Group string `yaml:"group,omitempty"`
+}
+
+// Autostart makes the agent start an instance when it boots.
+type Autostart struct {
+	Enabled  bool   `yaml:"enabled"`
+	Delay    string `yaml:"delay,omitempty"`    // wait before starting it, e.g. "30s"
+	Priority int    `yaml:"priority,omitempty"` // higher starts first
+}
+
+// AutostartDelay parses an instance's autostart delay (0 if unset).
+func AutostartDelay(instanceName string, a Autostart) (time.Duration, error) {
+	if strings.TrimSpace(a.Delay) == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(a.Delay))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("instance %q has invalid autostart.delay %q (expected a duration, e.g. 30s)", instanceName, a.Delay)
+	}
+	return d, nil
+}
+
+// Schedule is a task the agent runs for an instance on a cron schedule.
+type Schedule struct {
+	Name    string `yaml:"name"`
+	Cron    string `yaml:"cron"`              // e.g. "0 4 * * *" or "@daily" (agent local time)
+	Action  string `yaml:"action"`            // start, stop, restart, stdin or hook
+	Command string `yaml:"command,omitempty"` // stdin: text to send; hook: shell script
+	Timeout string `yaml:"timeout,omitempty"` // hook: e.g. "10m" (default 60s)
 }
 
 func LoadInstances(path string) (*InstanceConfig, error) {
@@ -39,14 +79,26 @@ func LoadInstances(path string) (*InstanceConfig, error) {
 		if inst.Template == "" {
 			return nil, fmt.Errorf("instance %q missing template", name)
 		}
+		if _, err := ConvertSchedules(name, inst.Schedules); err != nil {
+			return nil, err
+		}
+		if _, err := AutostartDelay(name, inst.Autostart); err != nil {
+			return nil, err
+		}
 		if inst.Params == nil {
-			cfg.Instances[name] = Instance{
-				Template: inst.Template,
-				Enabled:  inst.Enabled,
-				Params:   map[string]string{},
-			}
+			inst.Params = map[string]string{}
+			cfg.Instances[name] = inst
 		}
 	}
 
+	names := make([]string, 0, len(cfg.Instances))
+	for name := range cfg.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if _, err := DependencyOrder(cfg.Instances, names); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
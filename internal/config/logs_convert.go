@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func ConvertLogs(serverName string, l Logs) (manager.LogRotation, error) {
+	var cfg manager.LogRotation
+
+	if strings.TrimSpace(l.MaxSize) != "" {
+		n, err := ParseSize(l.MaxSize)
+		if err != nil {
+			return manager.LogRotation{}, fmt.Errorf("server %q has invalid logs.max_size: %w", serverName, err)
+		}
+		cfg.MaxSize = n
+	}
+
+	if strings.TrimSpace(l.RotateEvery) != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(l.RotateEvery))
+		if err != nil {
+			return manager.LogRotation{}, fmt.Errorf("server %q has invalid logs.rotate_every %q: %w", serverName, l.RotateEvery, err)
+		}
+		cfg.RotateEvery = d
+	}
+
+	if l.MaxFiles < 0 {
+		return manager.LogRotation{}, fmt.Errorf("server %q has invalid logs.max_files %d", serverName, l.MaxFiles)
+	}
+	if l.MaxAgeDays < 0 {
+		return manager.LogRotation{}, fmt.Errorf("server %q has invalid logs.max_age_days %d", serverName, l.MaxAgeDays)
+	}
+	cfg.MaxFiles = l.MaxFiles
+	cfg.MaxAge = time.Duration(l.MaxAgeDays) * 24 * time.Hour
+	cfg.Compress = l.Compress
+
+	return cfg, nil
+}
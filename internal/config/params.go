@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamType is the type of a template parameter.
+type ParamType string
+
+const (
+	ParamString ParamType = "string"
+	ParamInt    ParamType = "int"
+	ParamBool   ParamType = "bool" // yes/no, on/off and 1/0 too; normalized to "true" or "false"
+	ParamSize   ParamType = "size" // e.g. 512M or 4G (see ParseSize)
+	ParamPort   ParamType = "port" // 1-65535
+	ParamEnum   ParamType = "enum" // one of Values
+)
+
+// Param declares a parameter a template accepts. A template with a params
+// schema only accepts the params it declares.
+type Param struct {
+	Name        string    `yaml:"name"`
+	Type        ParamType `yaml:"type,omitempty"` // default string
+	Required    bool      `yaml:"required,omitempty"`
+	Default     string    `yaml:"default,omitempty"`
+	Values      []string  `yaml:"values,omitempty"`  // enum: allowed values
+	Pattern     string    `yaml:"pattern,omitempty"` // regex the whole value must match
+	Description string    `yaml:"description,omitempty"`
+}
+
+// reservedParams are set by the agent for every instance.
+var reservedParams = map[string]bool{"instance_name": true, "instance_dir": true, "log_path": true}
+
+// ValidateParamSchema checks a template's params schema, including that
+// every default is a valid value.
+func ValidateParamSchema(templateName string, schema []Param) error {
+	seen := map[string]bool{}
+	for _, p := range schema {
+		if p.Name == "" {
+			return fmt.Errorf("template %q has a param without a name", templateName)
+		}
+		if reservedParams[p.Name] {
+			return fmt.Errorf("template %q declares param %q, which the agent sets itself", templateName, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("template %q declares param %q twice", templateName, p.Name)
+		}
+		seen[p.Name] = true
+
+		switch p.Type {
+		case "", ParamString, ParamInt, ParamBool, ParamSize, ParamPort:
+			if len(p.Values) > 0 {
+				return fmt.Errorf("template %q param %q: values only apply to type enum", templateName, p.Name)
+			}
+		case ParamEnum:
+			if len(p.Values) == 0 {
+				return fmt.Errorf("template %q param %q: type enum needs values", templateName, p.Name)
+			}
+		default:
+			return fmt.Errorf("template %q param %q has invalid type %q (expected string, int, bool, size, port or enum)", templateName, p.Name, p.Type)
+		}
+		if p.Pattern != "" {
+			if _, err := compilePattern(p.Pattern); err != nil {
+				return fmt.Errorf("template %q param %q has invalid pattern %q: %w", templateName, p.Name, p.Pattern, err)
+			}
+		}
+		if p.Required && p.Default != "" {
+			return fmt.Errorf("template %q param %q is required, so it cannot have a default", templateName, p.Name)
+		}
+		if p.Default != "" {
+			if _, err := checkParam(p, p.Default); err != nil {
+				return fmt.Errorf("template %q param %q has invalid default: %w", templateName, p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyParams checks an instance's params against its template's schema and
+// returns them with defaults filled in and bools normalized. Every problem
+// is reported, not just the first. Without a schema, params pass through
+// unchecked.
+func ApplyParams(templateName string, schema []Param, params map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(params)+len(schema))
+	for k, v := range params {
+		out[k] = v
+	}
+	if len(schema) == 0 {
+		return out, nil
+	}
+
+	declared := make(map[string]Param, len(schema))
+	names := make([]string, 0, len(schema))
+	for _, p := range schema {
+		declared[p.Name] = p
+		names = append(names, p.Name)
+	}
+
+	var problems []string
+	given := make([]string, 0, len(params))
+	for k := range params {
+		given = append(given, k)
+	}
+	sort.Strings(given)
+	for _, k := range given {
+		if _, ok := declared[k]; ok {
+			continue
+		}
+		msg := fmt.Sprintf("unknown param %q", k)
+		if near := closest(k, names); near != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", near)
+		}
+		problems = append(problems, msg)
+	}
+
+	for _, p := range schema {
+		v, ok := params[p.Name]
+		if !ok {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("missing required param %q", p.Name))
+			} else if p.Default != "" {
+				out[p.Name], _ = checkParam(p, p.Default) // validated on load
+			}
+			continue
+		}
+		norm, err := checkParam(p, v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("param %q: %v", p.Name, err))
+			continue
+		}
+		out[p.Name] = norm
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("template %q: %s", templateName, strings.Join(problems, "; "))
+	}
+	return out, nil
+}
+
+// checkParam validates v against p and returns it normalized.
+func checkParam(p Param, v string) (string, error) {
+	switch p.Type {
+	case ParamInt:
+		if _, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err != nil {
+			return "", fmt.Errorf("%q is not an integer", v)
+		}
+	case ParamBool:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "on", "1":
+			v = "true"
+		case "false", "no", "off", "0":
+			v = "false"
+		default:
+			return "", fmt.Errorf("%q is not a bool (true or false)", v)
+		}
+	case ParamSize:
+		if _, err := ParseSize(v); err != nil {
+			return "", err
+		}
+	case ParamPort:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("%q is not a port (1-65535)", v)
+		}
+	case ParamEnum:
+		ok := false
+		for _, allowed := range p.Values {
+			ok = ok || v == allowed
+		}
+		if !ok {
+			return "", fmt.Errorf("%q is not one of %s", v, strings.Join(p.Values, ", "))
+		}
+	}
+
+	if p.Pattern != "" {
+		re, _ := compilePattern(p.Pattern) // validated on load
+		if !re.MatchString(v) {
+			return "", fmt.Errorf("%q does not match %s", v, p.Pattern)
+		}
+	}
+	return v, nil
+}
+
+// compilePattern anchors a param pattern so it matches whole values.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// closest returns the name nearest to s by edit distance, if it is close
+// enough to be a likely typo.
+func closest(s string, names []string) string {
+	best, bestDist := "", 3
+	for _, n := range names {
+		if d := editDistance(s, n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
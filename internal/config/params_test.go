@@ -0,0 +1,229 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   Param
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"string", Param{}, "anything goes", "anything goes", false},
+		{"string empty", Param{Type: ParamString}, "", "", false},
+
+		{"int", Param{Type: ParamInt}, "42", "42", false},
+		{"int negative", Param{Type: ParamInt}, "-7", "-7", false},
+		{"int spaces", Param{Type: ParamInt}, " 8 ", " 8 ", false},
+		{"int float", Param{Type: ParamInt}, "1.5", "", true},
+		{"int word", Param{Type: ParamInt}, "ten", "", true},
+		{"int empty", Param{Type: ParamInt}, "", "", true},
+
+		{"bool true", Param{Type: ParamBool}, "true", "true", false},
+		{"bool yes", Param{Type: ParamBool}, "Yes", "true", false},
+		{"bool on", Param{Type: ParamBool}, "on", "true", false},
+		{"bool 1", Param{Type: ParamBool}, "1", "true", false},
+		{"bool false", Param{Type: ParamBool}, "FALSE", "false", false},
+		{"bool no", Param{Type: ParamBool}, " no ", "false", false},
+		{"bool off", Param{Type: ParamBool}, "off", "false", false},
+		{"bool 0", Param{Type: ParamBool}, "0", "false", false},
+		{"bool maybe", Param{Type: ParamBool}, "maybe", "", true},
+		{"bool 2", Param{Type: ParamBool}, "2", "", true},
+
+		{"size", Param{Type: ParamSize}, "4G", "4G", false},
+		{"size bytes", Param{Type: ParamSize}, "512", "512", false},
+		{"size bad suffix", Param{Type: ParamSize}, "4X", "", true},
+		{"size negative", Param{Type: ParamSize}, "-1M", "", true},
+
+		{"port low", Param{Type: ParamPort}, "1", "1", false},
+		{"port high", Param{Type: ParamPort}, "65535", "65535", false},
+		{"port zero", Param{Type: ParamPort}, "0", "", true},
+		{"port too high", Param{Type: ParamPort}, "65536", "", true},
+		{"port negative", Param{Type: ParamPort}, "-25565", "", true},
+		{"port word", Param{Type: ParamPort}, "http", "", true},
+
+		{"enum", Param{Type: ParamEnum, Values: []string{"easy", "hard"}}, "hard", "hard", false},
+		{"enum case sensitive", Param{Type: ParamEnum, Values: []string{"easy", "hard"}}, "Hard", "", true},
+		{"enum other", Param{Type: ParamEnum, Values: []string{"easy", "hard"}}, "medium", "", true},
+
+		{"pattern", Param{Pattern: `[a-z]+`}, "world", "world", false},
+		{"pattern is anchored", Param{Pattern: `[a-z]+`}, "world2", "", true},
+		{"pattern alternation is anchored", Param{Pattern: `a|b`}, "ab", "", true},
+		{"pattern with type", Param{Type: ParamInt, Pattern: `25\d{3}`}, "25565", "25565", false},
+		{"pattern with type mismatch", Param{Type: ParamInt, Pattern: `25\d{3}`}, "19132", "", true},
+		{"pattern sees normalized bool", Param{Type: ParamBool, Pattern: "true"}, "yes", "true", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkParam(tt.param, tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("checkParam(%q) = %q, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkParam(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("checkParam(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyParams(t *testing.T) {
+	schema := []Param{
+		{Name: "world", Required: true},
+		{Name: "port", Type: ParamPort, Default: "25565"},
+		{Name: "mem_max", Type: ParamSize, Default: "2G"},
+		{Name: "pvp", Type: ParamBool, Default: "yes"},
+		{Name: "motd"},
+	}
+	tests := []struct {
+		name    string
+		schema  []Param
+		params  map[string]string
+		want    map[string]string
+		wantErr []string
+	}{
+		{
+			name:   "no schema passes params through",
+			params: map[string]string{"anything": "goes", "port": "not a port"},
+			want:   map[string]string{"anything": "goes", "port": "not a port"},
+		},
+		{
+			name: "no schema and no params",
+			want: map[string]string{},
+		},
+		{
+			name:   "defaults fill in missing params",
+			schema: schema,
+			params: map[string]string{"world": "survival"},
+			want:   map[string]string{"world": "survival", "port": "25565", "mem_max": "2G", "pvp": "true"},
+		},
+		{
+			name:   "given params override defaults",
+			schema: schema,
+			params: map[string]string{"world": "creative", "port": "25566", "pvp": "off", "motd": "hi"},
+			want:   map[string]string{"world": "creative", "port": "25566", "mem_max": "2G", "pvp": "false", "motd": "hi"},
+		},
+		{
+			name:   "empty value is kept over the default",
+			schema: schema,
+			params: map[string]string{"world": "w", "motd": ""},
+			want:   map[string]string{"world": "w", "port": "25565", "mem_max": "2G", "pvp": "true", "motd": ""},
+		},
+		{
+			name:    "missing required param",
+			schema:  schema,
+			params:  map[string]string{},
+			wantErr: []string{`missing required param "world"`},
+		},
+		{
+			name:    "unknown param suggests the closest",
+			schema:  schema,
+			params:  map[string]string{"world": "w", "prot": "1"},
+			wantErr: []string{`unknown param "prot" (did you mean "port"?)`},
+		},
+		{
+			name:    "unknown param without a close match",
+			schema:  schema,
+			params:  map[string]string{"world": "w", "difficulty": "hard"},
+			wantErr: []string{`unknown param "difficulty"`},
+		},
+		{
+			name:   "every problem is reported",
+			schema: schema,
+			params: map[string]string{"port": "0", "pvp": "maybe", "zzz": "1"},
+			wantErr: []string{
+				`unknown param "zzz"`,
+				`missing required param "world"`,
+				`param "port": "0" is not a port`,
+				`param "pvp": "maybe" is not a bool`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyParams("mc", tt.schema, tt.params)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("ApplyParams = %v, want error", got)
+				}
+				for _, w := range tt.wantErr {
+					if !strings.Contains(err.Error(), w) {
+						t.Errorf("ApplyParams error %q does not contain %q", err, w)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyParams: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplyParams = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyParamsDoesNotModifyInput(t *testing.T) {
+	params := map[string]string{"pvp": "yes"}
+	if _, err := ApplyParams("mc", []Param{{Name: "pvp", Type: ParamBool}, {Name: "port", Default: "1"}}, params); err != nil {
+		t.Fatalf("ApplyParams: %v", err)
+	}
+	if want := map[string]string{"pvp": "yes"}; !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v after ApplyParams, want %v", params, want)
+	}
+}
+
+func TestValidateParamSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  []Param
+		wantErr string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			schema: []Param{
+				{Name: "world", Required: true},
+				{Name: "port", Type: ParamPort, Default: "25565"},
+				{Name: "mode", Type: ParamEnum, Values: []string{"a", "b"}, Default: "b"},
+				{Name: "seed", Type: ParamInt, Pattern: `-?\d+`},
+			},
+		},
+		{"missing name", []Param{{Type: ParamInt}}, "param without a name"},
+		{"reserved name", []Param{{Name: "instance_dir"}}, "which the agent sets itself"},
+		{"duplicate", []Param{{Name: "a"}, {Name: "a"}}, `declares param "a" twice`},
+		{"unknown type", []Param{{Name: "a", Type: "float"}}, `invalid type "float"`},
+		{"values on non-enum", []Param{{Name: "a", Values: []string{"x"}}}, "values only apply to type enum"},
+		{"enum without values", []Param{{Name: "a", Type: ParamEnum}}, "type enum needs values"},
+		{"bad pattern", []Param{{Name: "a", Pattern: "("}}, "invalid pattern"},
+		{"required with default", []Param{{Name: "a", Required: true, Default: "x"}}, "cannot have a default"},
+		{"invalid int default", []Param{{Name: "a", Type: ParamInt, Default: "x"}}, "invalid default"},
+		{"invalid port default", []Param{{Name: "a", Type: ParamPort, Default: "70000"}}, "invalid default"},
+		{"invalid enum default", []Param{{Name: "a", Type: ParamEnum, Values: []string{"x"}, Default: "y"}}, "invalid default"},
+		{"default not matching pattern", []Param{{Name: "a", Pattern: "[0-9]+", Default: "x"}}, "invalid default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateParamSchema("mc", tt.schema)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateParamSchema: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateParamSchema error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
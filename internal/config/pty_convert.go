@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func ConvertPty(serverName string, p Pty) (manager.Pty, error) {
+	if !p.Enabled {
+		return manager.Pty{}, nil
+	}
+	if p.Cols < 0 || p.Cols > 0xffff || p.Rows < 0 || p.Rows > 0xffff {
+		return manager.Pty{}, fmt.Errorf("server %q has invalid pty size %dx%d", serverName, p.Cols, p.Rows)
+	}
+	if (p.Cols == 0) != (p.Rows == 0) {
+		return manager.Pty{}, fmt.Errorf("server %q must set both pty.cols and pty.rows", serverName)
+	}
+	return manager.Pty{
+		Enabled:   true,
+		StripANSI: p.StripANSI,
+		Cols:      uint16(p.Cols),
+		Rows:      uint16(p.Rows),
+	}, nil
+}
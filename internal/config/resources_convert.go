@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func ConvertResources(serverName string, r Resources) (manager.Resources, error) {
+	var cfg manager.Resources
+
+	sizes := []struct {
+		field string
+		raw   string
+		dst   *int64
+	}{
+		{"memory_max", r.MemoryMax, &cfg.MemoryMax},
+		{"memory_high", r.MemoryHigh, &cfg.MemoryHigh},
+	}
+	for _, sz := range sizes {
+		if strings.TrimSpace(sz.raw) == "" {
+			continue
+		}
+		n, err := ParseSize(sz.raw)
+		if err != nil {
+			return manager.Resources{}, fmt.Errorf("server %q has invalid resources.%s: %w", serverName, sz.field, err)
+		}
+		*sz.dst = n
+	}
+
+	weights := []struct {
+		field string
+		v     int
+	}{
+		{"cpu_weight", r.CPUWeight},
+		{"io_weight", r.IOWeight},
+	}
+	for _, w := range weights {
+		if w.v != 0 && (w.v < 1 || w.v > 10000) {
+			return manager.Resources{}, fmt.Errorf("server %q has invalid resources.%s %d (expected 1-10000)", serverName, w.field, w.v)
+		}
+	}
+	cfg.CPUWeight = r.CPUWeight
+	cfg.IOWeight = r.IOWeight
+
+	if strings.TrimSpace(r.CPUQuota) != "" {
+		pct, err := parseCPUQuota(r.CPUQuota)
+		if err != nil {
+			return manager.Resources{}, fmt.Errorf("server %q has invalid resources.cpu_quota %q: %w", serverName, r.CPUQuota, err)
+		}
+		cfg.CPUQuotaPercent = pct
+	}
+
+	if r.PidsMax < 0 {
+		return manager.Resources{}, fmt.Errorf("server %q has invalid resources.pids_max %d", serverName, r.PidsMax)
+	}
+	cfg.PidsMax = r.PidsMax
+
+	return cfg, nil
+}
+
+// parseCPUQuota accepts "150%" or a CPU count like "1.5" and returns percent
+// of one CPU.
+func parseCPUQuota(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s, "%")))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("expected a positive percentage like 150%%")
+		}
+		return n, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("expected a CPU count like 1.5 or a percentage like 150%%")
+	}
+	return int(f * 100), nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func ConvertRestart(serverName string, r Restart) (manager.RestartPolicy, error) {
+	// defaults
+	cfg := manager.RestartPolicy{
+		Mode:        manager.RestartNever,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		MaxRestarts: 5,
+		Window:      10 * time.Minute,
+	}
+
+	if strings.TrimSpace(r.Policy) != "" {
+		switch strings.ToLower(strings.TrimSpace(r.Policy)) {
+		case "never", "no":
+			cfg.Mode = manager.RestartNever
+		case "on-failure":
+			cfg.Mode = manager.RestartOnFailure
+		case "always":
+			cfg.Mode = manager.RestartAlways
+		default:
+			return manager.RestartPolicy{}, fmt.Errorf("server %q has invalid restart.policy %q (expected never|on-failure|always)", serverName, r.Policy)
+		}
+	}
+
+	durations := []struct {
+		field string
+		raw   string
+		dst   *time.Duration
+	}{
+		{"backoff", r.Backoff, &cfg.Backoff},
+		{"max_backoff", r.MaxBackoff, &cfg.MaxBackoff},
+		{"window", r.Window, &cfg.Window},
+	}
+	for _, d := range durations {
+		if strings.TrimSpace(d.raw) == "" {
+			continue
+		}
+		v, err := time.ParseDuration(strings.TrimSpace(d.raw))
+		if err != nil {
+			return manager.RestartPolicy{}, fmt.Errorf("server %q has invalid restart.%s %q: %w", serverName, d.field, d.raw, err)
+		}
+		*d.dst = v
+	}
+
+	if r.MaxRestarts != nil {
+		if *r.MaxRestarts < 0 {
+			return manager.RestartPolicy{}, fmt.Errorf("server %q has invalid restart.max_restarts %d", serverName, *r.MaxRestarts)
+		}
+		cfg.MaxRestarts = *r.MaxRestarts
+	}
+
+	return cfg, nil
+}
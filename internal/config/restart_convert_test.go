@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func TestConvertRestart(t *testing.T) {
+	intp := func(n int) *int { return &n }
+	defaults := manager.RestartPolicy{
+		Mode:        manager.RestartNever,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		MaxRestarts: 5,
+		Window:      10 * time.Minute,
+	}
+	with := func(f func(*manager.RestartPolicy)) manager.RestartPolicy {
+		p := defaults
+		f(&p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		in      Restart
+		want    manager.RestartPolicy
+		wantErr bool
+	}{
+		{name: "defaults", in: Restart{}, want: defaults},
+		{name: "always", in: Restart{Policy: "Always"}, want: with(func(p *manager.RestartPolicy) { p.Mode = manager.RestartAlways })},
+		{name: "on-failure", in: Restart{Policy: " on-failure "}, want: with(func(p *manager.RestartPolicy) { p.Mode = manager.RestartOnFailure })},
+		{name: "no", in: Restart{Policy: "no"}, want: defaults},
+		{name: "durations", in: Restart{Backoff: "1s", MaxBackoff: "30s", Window: "1h"}, want: with(func(p *manager.RestartPolicy) {
+			p.Backoff, p.MaxBackoff, p.Window = time.Second, 30*time.Second, time.Hour
+		})},
+		{name: "unset max_restarts keeps the default", in: Restart{MaxRestarts: nil}, want: defaults},
+		{name: "max_restarts", in: Restart{MaxRestarts: intp(2)}, want: with(func(p *manager.RestartPolicy) { p.MaxRestarts = 2 })},
+		{name: "max_restarts 0 is unlimited", in: Restart{MaxRestarts: intp(0)}, want: with(func(p *manager.RestartPolicy) { p.MaxRestarts = 0 })},
+		{name: "negative max_restarts", in: Restart{MaxRestarts: intp(-1)}, wantErr: true},
+		{name: "bad policy", in: Restart{Policy: "sometimes"}, wantErr: true},
+		{name: "bad backoff", in: Restart{Backoff: "soon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertRestart("mc", tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ConvertRestart = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertRestart: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertRestart = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// ConvertRunAs looks the user and groups up on this host, so it fails when
+// the user doesn't exist.
+func ConvertRunAs(serverName string, r RunAs) (manager.RunAs, error) {
+	var out manager.RunAs
+
+	if u := strings.TrimSpace(r.Umask); u != "" {
+		v, err := strconv.ParseUint(u, 8, 32)
+		if err != nil || v > 0o777 {
+			return manager.RunAs{}, fmt.Errorf("server %q has invalid run_as.umask %q (expected octal, e.g. 027)", serverName, r.Umask)
+		}
+		out.Umask = fmt.Sprintf("%04o", v)
+	}
+
+	name := strings.TrimSpace(r.User)
+	if name == "" {
+		if r.Group != "" || len(r.Groups) > 0 {
+			return manager.RunAs{}, fmt.Errorf("server %q run_as.group/groups require run_as.user", serverName)
+		}
+		return out, nil
+	}
+
+	u, err := lookupUser(name)
+	if err != nil {
+		return manager.RunAs{}, fmt.Errorf("server %q run_as.user: %w", serverName, err)
+	}
+	uid, err := parseID(u.Uid)
+	if err != nil {
+		return manager.RunAs{}, fmt.Errorf("server %q run_as.user %q: %w", serverName, name, err)
+	}
+	out.User = u.Username
+	out.UID = uid
+
+	// Primary group defaults to the user's own
+	gidStr := u.Gid
+	if g := strings.TrimSpace(r.Group); g != "" {
+		if gidStr, err = lookupGroupID(g); err != nil {
+			return manager.RunAs{}, fmt.Errorf("server %q run_as.group: %w", serverName, err)
+		}
+	}
+	if out.GID, err = parseID(gidStr); err != nil {
+		return manager.RunAs{}, fmt.Errorf("server %q run_as.group %q: %w", serverName, gidStr, err)
+	}
+
+	// An empty list keeps no supplementary groups at all, rather than the agent's
+	out.Groups = []uint32{}
+	for _, g := range r.Groups {
+		idStr, err := lookupGroupID(strings.TrimSpace(g))
+		if err != nil {
+			return manager.RunAs{}, fmt.Errorf("server %q run_as.groups: %w", serverName, err)
+		}
+		id, err := parseID(idStr)
+		if err != nil {
+			return manager.RunAs{}, fmt.Errorf("server %q run_as.groups %q: %w", serverName, g, err)
+		}
+		out.Groups = append(out.Groups, id)
+	}
+
+	return out, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("user %q does not exist on this host", name)
+	}
+	return u, nil
+}
+
+func lookupGroupID(name string) (string, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return name, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", fmt.Errorf("group %q does not exist on this host", name)
+	}
+	return g.Gid, nil
+}
+
+func parseID(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("non-numeric id %q", s)
+	}
+	return uint32(v), nil
+}
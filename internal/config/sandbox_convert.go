@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// ConvertSandbox expects Writable and Hostname to be rendered already. The
+// instance directory is always writable.
+func ConvertSandbox(serverName string, s Sandbox, instanceDir string) (manager.Sandbox, error) {
+	if !s.Enabled {
+		return manager.Sandbox{}, nil
+	}
+
+	out := manager.Sandbox{
+		Enabled:        true,
+		PrivateNetwork: s.PrivateNetwork,
+		Hostname:       strings.TrimSpace(s.Hostname),
+	}
+
+	for _, p := range append([]string{instanceDir}, s.Writable...) {
+		abs, err := filepath.Abs(strings.TrimSpace(p))
+		if err != nil {
+			return manager.Sandbox{}, fmt.Errorf("server %q has invalid sandbox.writable %q: %w", serverName, p, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return manager.Sandbox{}, fmt.Errorf("server %q sandbox.writable %q: %w", serverName, p, err)
+		}
+		out.Writable = append(out.Writable, abs)
+	}
+	return out, nil
+}
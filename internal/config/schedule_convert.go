@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/schedule"
+)
+
+func ConvertSchedules(instanceName string, in []Schedule) ([]schedule.Entry, error) {
+	out := make([]schedule.Entry, 0, len(in))
+	seen := map[string]bool{}
+
+	for i, sc := range in {
+		name := strings.TrimSpace(sc.Name)
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", sc.Action, i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("instance %q has duplicate schedule name %q", instanceName, name)
+		}
+		seen[name] = true
+
+		spec, err := schedule.Parse(sc.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("instance %q has invalid schedules[%d].cron %q: %w", instanceName, i, sc.Cron, err)
+		}
+
+		action, err := schedule.ParseAction(strings.ToLower(strings.TrimSpace(sc.Action)))
+		if err != nil {
+			return nil, fmt.Errorf("instance %q has invalid schedules[%d].action: %w", instanceName, i, err)
+		}
+		if (action == schedule.ActionStdin || action == schedule.ActionHook) && strings.TrimSpace(sc.Command) == "" {
+			return nil, fmt.Errorf("instance %q schedules[%d] action %s requires command", instanceName, i, action)
+		}
+
+		e := schedule.Entry{
+			Instance: instanceName,
+			Name:     name,
+			Spec:     spec,
+			Action:   action,
+			Command:  sc.Command,
+		}
+		if sc.Timeout != "" {
+			d, err := time.ParseDuration(sc.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("instance %q has invalid schedules[%d].timeout %q: %w", instanceName, i, sc.Timeout, err)
+			}
+			e.Timeout = d
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
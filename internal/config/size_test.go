@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"64K", 64 << 10},
+		{"64k", 64 << 10},
+		{"64KB", 64 << 10},
+		{"64KiB", 64 << 10},
+		{"100M", 100 << 20},
+		{"2G", 2 << 30},
+		{"1GiB", 1 << 30},
+		{"3T", 3 << 40},
+		{" 4g ", 4 << 30},
+		{"8 M", 8 << 20},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil {
+			t.Errorf("ParseSize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, in := range []string{"", " ", "M", "-1", "-1G", "1.5G", "1X", "G1", "1GG", "ten", "99999999999T"} {
+		if got, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want error", in, got)
+		}
+	}
+}
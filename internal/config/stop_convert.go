@@ -17,6 +17,18 @@ func ConvertStopPublic(serverName string, s Stop) (manager.StopConfig, error) {
 		GracePeriod: 10 * time.Second,
 	}
 
+	if len(s.Steps) > 0 {
+		if strings.TrimSpace(s.Type) != "" || s.Command != "" || s.Signal != "" || s.GracePeriod != "" {
+			return manager.StopConfig{}, fmt.Errorf("server %q stop.steps cannot be combined with stop.type/command/signal/grace_period", serverName)
+		}
+		steps, err := convertStopSteps(serverName, s.Steps)
+		if err != nil {
+			return manager.StopConfig{}, err
+		}
+		cfg.Steps = steps
+		return cfg, nil
+	}
+
 	if strings.TrimSpace(s.Type) != "" {
 		switch strings.ToLower(strings.TrimSpace(s.Type)) {
 		case "stdin":
@@ -59,6 +71,43 @@ func ConvertStopPublic(serverName string, s Stop) (manager.StopConfig, error) {
 	return cfg, nil
 }
 
+func convertStopSteps(serverName string, in []StopStep) ([]manager.StopStep, error) {
+	out := make([]manager.StopStep, 0, len(in))
+	for i, st := range in {
+		hasStdin := st.Stdin != ""
+		hasSignal := strings.TrimSpace(st.Signal) != ""
+		if hasStdin == hasSignal {
+			return nil, fmt.Errorf("server %q stop.steps[%d] must set exactly one of stdin or signal", serverName, i)
+		}
+
+		step := manager.StopStep{
+			Wait:      10 * time.Second,
+			ExitEarly: st.ExitEarly == nil || *st.ExitEarly,
+		}
+		if hasStdin {
+			step.Type = manager.StopStdin
+			step.StdinCommand = st.Stdin
+		} else {
+			sig, err := parseSignal(st.Signal)
+			if err != nil {
+				return nil, fmt.Errorf("server %q has invalid stop.steps[%d].signal %q: %w", serverName, i, st.Signal, err)
+			}
+			step.Type = manager.StopSignal
+			step.Signal = sig
+		}
+
+		if strings.TrimSpace(st.Wait) != "" {
+			d, err := time.ParseDuration(strings.TrimSpace(st.Wait))
+			if err != nil {
+				return nil, fmt.Errorf("server %q has invalid stop.steps[%d].wait %q: %w", serverName, i, st.Wait, err)
+			}
+			step.Wait = d
+		}
+		out = append(out, step)
+	}
+	return out, nil
+}
+
 func parseSignal(s string) (syscall.Signal, error) {
 	u := strings.ToUpper(strings.TrimSpace(s))
 	if !strings.HasPrefix(u, "SIG") {
@@ -12,11 +12,25 @@ type TemplateConfig struct {
 }
 
 type Template struct {
+	Description string `yaml:"description,omitempty"`
+
+	// Params declares the params instances may (or must) set; see Param
+	Params []Param `yaml:"params,omitempty"`
+
 	Command string   `yaml:"command"`
 	Args    []string `yaml:"args"`
 	Cwd     string   `yaml:"cwd"`
 	Env     []string `yaml:"env"`
 	Stop    Stop     `yaml:"stop"`
+	Restart Restart  `yaml:"restart"`
+	Logs    Logs     `yaml:"logs"`
+
+	Resources Resources `yaml:"resources"`
+	Health    Health    `yaml:"health"`
+	Hooks     Hooks     `yaml:"hooks"`
+	RunAs     RunAs     `yaml:"run_as"`
+	Sandbox   Sandbox   `yaml:"sandbox"`
+	Pty       Pty       `yaml:"pty"`
 }
 
 func LoadTemplates(path string) (*TemplateConfig, error) {
@@ -25,15 +39,28 @@ func LoadTemplates(path string) (*TemplateConfig, error) {
 		return nil, fmt.Errorf("read templates file %q: %w", path, err)
 	}
 
-	var cfg TemplateConfig
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
+	var src templateSource
+	if err := yaml.Unmarshal(b, &src); err != nil {
 		return nil, fmt.Errorf("parse templates yaml %q: %w", path, err)
 	}
 
-	if len(cfg.Templates) == 0 {
+	if len(src.Templates) == 0 {
 		return nil, fmt.Errorf("templates config contains no templates")
 	}
 
+	nodes, err := resolveTemplates(src)
+	if err != nil {
+		return nil, fmt.Errorf("templates %q: %w", path, err)
+	}
+	cfg := TemplateConfig{Templates: make(map[string]Template, len(nodes))}
+	for name, n := range nodes {
+		var t Template
+		if err := n.Decode(&t); err != nil {
+			return nil, fmt.Errorf("parse template %q: %w", name, err)
+		}
+		cfg.Templates[name] = t
+	}
+
 	for name, t := range cfg.Templates {
 		if name == "" {
 			return nil, fmt.Errorf("template name cannot be empty")
@@ -41,6 +68,9 @@ func LoadTemplates(path string) (*TemplateConfig, error) {
 		if t.Command == "" {
 			return nil, fmt.Errorf("template %q missing command", name)
 		}
+		if err := ValidateParamSchema(name, t.Params); err != nil {
+			return nil, err
+		}
 	}
 
 	return &cfg, nil
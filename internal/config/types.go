@@ -20,4 +20,100 @@ type Stop struct {
 	Command     string `yaml:"command"`      // for stdin stop (e.g. "stop\n")
 	Signal      string `yaml:"signal"`       // for signal stop (e.g. "SIGTERM")
 	GracePeriod string `yaml:"grace_period"` // e.g. "15s"
+
+	// Steps is an ordered escalation chain used instead of the fields above
+	Steps []StopStep `yaml:"steps"`
+}
+
+// StopStep sets exactly one of Stdin or Signal.
+type StopStep struct {
+	Stdin     string `yaml:"stdin"`      // console command to send (e.g. "save-all")
+	Signal    string `yaml:"signal"`     // signal to send to the process group (e.g. "SIGTERM")
+	Wait      string `yaml:"wait"`       // how long to wait for the process to exit (default 10s)
+	ExitEarly *bool  `yaml:"exit_early"` // stop waiting once the process is gone (default true)
+}
+
+// Restart defines what the agent does when the server exits on its own
+type Restart struct {
+	Policy      string `yaml:"policy"`       // "never", "on-failure" or "always"
+	Backoff     string `yaml:"backoff"`      // delay before the first restart (e.g. "5s")
+	MaxBackoff  string `yaml:"max_backoff"`  // cap for the doubling backoff (e.g. "5m")
+	MaxRestarts *int   `yaml:"max_restarts"` // restarts allowed within window before crash-loop (default 5, 0 = unlimited)
+	Window      string `yaml:"window"`       // e.g. "10m"
+}
+
+// Logs defines rotation and retention of the instance log
+type Logs struct {
+	MaxSize     string `yaml:"max_size"`     // rotate once the log exceeds this size (e.g. "100M")
+	RotateEvery string `yaml:"rotate_every"` // rotate at least this often (e.g. "24h")
+	MaxFiles    int    `yaml:"max_files"`    // rotated segments to keep
+	MaxAgeDays  int    `yaml:"max_age_days"` // delete segments older than this
+	Compress    bool   `yaml:"compress"`     // gzip rotated segments
+}
+
+// Resources defines cgroup v2 limits applied to the server's process group
+type Resources struct {
+	MemoryMax  string `yaml:"memory_max"`  // hard limit (e.g. "4G"), memory.max
+	MemoryHigh string `yaml:"memory_high"` // throttling threshold (e.g. "3G"), memory.high
+	CPUWeight  int    `yaml:"cpu_weight"`  // relative share 1-10000, cpu.weight
+	CPUQuota   string `yaml:"cpu_quota"`   // CPU time cap, e.g. "150%" = 1.5 CPUs, cpu.max
+	PidsMax    int    `yaml:"pids_max"`    // pids.max
+	IOWeight   int    `yaml:"io_weight"`   // relative share 1-10000, io.weight
+}
+
+// Health defines how the agent decides an instance is ready and healthy
+type Health struct {
+	Type     string `yaml:"type"`     // "tcp", "http", "exec" or "log"
+	Address  string `yaml:"address"`  // tcp: host:port (e.g. "127.0.0.1:{{.port}}")
+	URL      string `yaml:"url"`      // http: URL to GET
+	Command  string `yaml:"command"`  // exec: shell command run in the instance cwd
+	Pattern  string `yaml:"pattern"`  // log: regex matched against new log lines (e.g. "Done \\(")
+	Interval string `yaml:"interval"` // e.g. "10s"
+	Timeout  string `yaml:"timeout"`  // per probe, e.g. "5s"
+
+	SuccessThreshold int `yaml:"success_threshold"` // consecutive successes to become ready
+	FailureThreshold int `yaml:"failure_threshold"` // consecutive failures to become unhealthy
+	RestartAfter     int `yaml:"restart_after"`     // consecutive failures that restart the instance (0 = never)
+}
+
+// Hooks are shell commands run around the instance lifecycle. They are
+// rendered like command/args and run in the instance directory.
+type Hooks struct {
+	PreStart  []Hook `yaml:"pre_start"`  // a failure aborts the start
+	PostStart []Hook `yaml:"post_start"` // run in the background once spawned
+	PreStop   []Hook `yaml:"pre_stop"`
+	PostStop  []Hook `yaml:"post_stop"` // after every exit
+	OnCrash   []Hook `yaml:"on_crash"`  // after an unexpected exit, before post_stop
+}
+
+type Hook struct {
+	Command string `yaml:"command"` // run with sh -c
+	Timeout string `yaml:"timeout"` // e.g. "30s" (default 60s)
+}
+
+// RunAs runs the instance process, its hooks and exec health checks as
+// another user (the agent must be root to switch users).
+type RunAs struct {
+	User   string   `yaml:"user"`   // name or numeric uid
+	Group  string   `yaml:"group"`  // primary group; defaults to the user's
+	Groups []string `yaml:"groups"` // supplementary groups (default none)
+	Umask  string   `yaml:"umask"`  // octal, e.g. "027"
+}
+
+// Sandbox runs the instance in its own PID/mount/IPC/UTS namespaces (Linux,
+// agent must be root). Everything but the instance directory and Writable
+// paths is read-only.
+type Sandbox struct {
+	Enabled        bool     `yaml:"enabled"`
+	PrivateNetwork bool     `yaml:"private_network"` // loopback only
+	Writable       []string `yaml:"writable"`        // extra read-write paths (rendered)
+	Hostname       string   `yaml:"hostname"`        // defaults to the instance name (rendered)
+}
+
+// Pty runs the instance on a pseudo-terminal instead of pipes (Linux).
+type Pty struct {
+	Enabled   bool `yaml:"enabled"`
+	StripANSI bool `yaml:"strip_ansi"` // remove colors and other escape sequences from the log
+	Cols      int  `yaml:"cols"`       // terminal size (default 80x24)
+	Rows      int  `yaml:"rows"`
 }
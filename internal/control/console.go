@@ -0,0 +1,73 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+const (
+	defaultConsoleWait = 1 * time.Second
+	maxConsoleOutput   = 256 * 1024
+	// A write to a server's stdin can take up to 5s before it times out, so
+	// waiting up to 3s on top stays below the 10s the command server (and
+	// ctl) give a console request
+	maxConsoleWait = 3 * time.Second
+)
+
+func (h *Handler) handleConsoleSend(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.ConsoleRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+	if req.Command == "" {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("command is required"))
+		return resp, nil
+	}
+	if !h.Instances.HasInstance(req.Server) {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", req.Server))
+		return resp, nil
+	}
+
+	wait := defaultConsoleWait
+	if req.WaitMS > 0 {
+		wait = time.Duration(req.WaitMS) * time.Millisecond
+	} else if req.WaitMS < 0 {
+		wait = 0
+	}
+	if wait > maxConsoleWait {
+		wait = maxConsoleWait
+	}
+
+	// Remember where the log ends so only the command's output is returned
+	logPath := h.Instances.LogPath(req.Server)
+	offset, err := manager.LogSize(logPath)
+	if err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("read log: %w", err))
+		return resp, nil
+	}
+
+	if err := h.Instances.Mgr.SendInput(req.Server, req.Command); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
+		return resp, nil
+	}
+
+	lines := []string{}
+	if wait > 0 {
+		time.Sleep(wait)
+		lines, err = manager.ReadLogSince(logPath, offset, maxConsoleOutput)
+		if err != nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("read log: %w", err))
+			return resp, nil
+		}
+	}
+
+	return protocol.NewResponse(h.AgentID, msg.ID, protocol.ConsoleResponse{
+		Server: req.Server,
+		Lines:  lines,
+	}, nil)
+}
@@ -0,0 +1,48 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/instances"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+func (h *Handler) handleGroup(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.GroupRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+
+	trigger := "group:" + req.Group
+	var results []instances.GroupResult
+	var err error
+	if msg.Type == protocol.CmdGroupStart {
+		results, err = h.Instances.StartGroup(req.Group, trigger)
+	} else {
+		results, err = h.Instances.StopGroup(req.Group, trigger)
+	}
+	if err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
+		return resp, nil
+	}
+
+	out := protocol.GroupResponse{OK: true, Group: req.Group, Results: []protocol.GroupResult{}}
+	for _, r := range results {
+		res := protocol.GroupResult{
+			Instance: r.Instance,
+			OK:       r.Err == nil && !r.Skipped,
+			Skipped:  r.Skipped,
+			State:    string(r.State),
+		}
+		if r.Err != nil {
+			res.Error = r.Err.Error()
+		}
+		if !res.OK {
+			out.OK = false
+		}
+		out.Results = append(out.Results, res)
+	}
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
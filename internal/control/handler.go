@@ -1,10 +1,14 @@
 package control
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
+	"github.com/faradayfan/remote-process-manager/internal/config"
 	"github.com/faradayfan/remote-process-manager/internal/instances"
+	"github.com/faradayfan/remote-process-manager/internal/manager"
 	"github.com/faradayfan/remote-process-manager/internal/protocol"
 )
 
@@ -12,22 +16,95 @@ type Handler struct {
 	AgentID   string
 	Instances *instances.Service
 
-	// Callback so the agent can update the command server’s registry after changes
-	OnInstanceListChanged func()
+	// The active connection (see SetConnection). It changes on every
+	// reconnect while requests are being handled, hence the lock.
+	connMu sync.Mutex
+	// send pushes unsolicited messages (log streams) over the connection
+	send func(protocol.Message) error
+	// onInstanceListChanged updates the command server’s registry after changes
+	onInstanceListChanged func()
+
+	streamsMu sync.Mutex
+	streams   map[string]context.CancelFunc // request id -> cancel
 }
 
 func NewHandler(agentID string, inst *instances.Service) *Handler {
 	return &Handler{
 		AgentID:   agentID,
 		Instances: inst,
+		streams:   map[string]context.CancelFunc{},
 	}
 }
 
+// SetConnection points the handler at a new connection to the command
+// server.
+func (h *Handler) SetConnection(send func(protocol.Message) error, onInstanceListChanged func()) {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	h.send = send
+	h.onInstanceListChanged = onInstanceListChanged
+}
+
+// InstanceListChanged lets the command server know the instance list or the
+// agent's state changed. Without a connection it does nothing; the next
+// registration carries the changes.
+func (h *Handler) InstanceListChanged() {
+	h.connMu.Lock()
+	fn := h.onInstanceListChanged
+	h.connMu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// sender returns the function pushing messages over the current connection,
+// or nil without one.
+func (h *Handler) sender() func(protocol.Message) error {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	return h.send
+}
+
 func (h *Handler) SupportedServers() []string {
 	// Protocol field is still called "servers", but values are instance names.
 	return h.Instances.ListInstanceNames()
 }
 
+// RegisterPayload describes the agent for (re-)registration with the
+// command server.
+func (h *Handler) RegisterPayload() protocol.RegisterPayload {
+	m := h.Instances.Maintenance()
+	return protocol.RegisterPayload{
+		Servers:      h.SupportedServers(),
+		Autostart:    h.AutostartReport(),
+		Cordoned:     m.Cordoned,
+		CordonReason: m.Reason,
+	}
+}
+
+// AutostartReport returns the autostart outcomes for the register payload.
+func (h *Handler) AutostartReport() []protocol.AutostartResult {
+	results := h.Instances.AutostartResults()
+	out := make([]protocol.AutostartResult, 0, len(results))
+	for _, r := range results {
+		out = append(out, protocol.AutostartResult{
+			Instance: r.Instance,
+			Outcome:  r.Outcome,
+			State:    string(r.State),
+			Error:    r.Error,
+			At:       r.At,
+		})
+	}
+	return out
+}
+
+func toConfigAutostart(in *protocol.AutostartSpec) config.Autostart {
+	if in == nil {
+		return config.Autostart{}
+	}
+	return config.Autostart{Enabled: in.Enabled, Delay: in.Delay, Priority: in.Priority}
+}
+
 func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 	if err := msg.ValidateBasic(); err != nil {
 		return protocol.Message{}, err
@@ -36,6 +113,13 @@ func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 		return protocol.Message{}, fmt.Errorf("handler only accepts request messages")
 	}
 
+	if startsWork(msg.Type) {
+		if err := h.Instances.CheckCordon(); err != nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
+			return resp, nil
+		}
+	}
+
 	switch msg.Type {
 
 	// --------------------
@@ -54,14 +138,21 @@ func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 			return resp, nil
 		}
 
-		if err := h.Instances.CreateInstance(req.Name, req.Template, req.Enabled, req.Params); err != nil {
+		inst := config.Instance{
+			Template:  req.Template,
+			Enabled:   req.Enabled,
+			Params:    req.Params,
+			Schedules: toConfigSchedules(req.Schedules),
+			Autostart: toConfigAutostart(req.Autostart),
+			DependsOn: req.DependsOn,
+			Group:     req.Group,
+		}
+		if err := h.Instances.CreateInstance(req.Name, inst); err != nil {
 			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
 			return resp, nil
 		}
 
-		if h.OnInstanceListChanged != nil {
-			h.OnInstanceListChanged()
-		}
+		h.InstanceListChanged()
 
 		return protocol.NewResponse(h.AgentID, msg.ID, map[string]any{
 			"ok":   true,
@@ -80,15 +171,22 @@ func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 			return resp, nil
 		}
 
-		if h.OnInstanceListChanged != nil {
-			h.OnInstanceListChanged()
-		}
+		h.InstanceListChanged()
 
 		return protocol.NewResponse(h.AgentID, msg.ID, map[string]any{
 			"ok":   true,
 			"name": req.Name,
 		}, nil)
 
+	case protocol.CmdInstancesHistory:
+		return h.handleInstancesHistory(msg)
+
+	case protocol.CmdTemplatesList:
+		return h.handleTemplatesList(msg)
+
+	case protocol.CmdTemplatesDescribe:
+		return h.handleTemplatesDescribe(msg)
+
 	// --------------------
 	// Process operations on an instance name
 	// --------------------
@@ -117,13 +215,7 @@ func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 			return resp, nil
 		}
 
-		cfg, logPath, err := h.Instances.ResolveConfig(tgt.Server)
-		if err != nil {
-			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
-			return resp, nil
-		}
-
-		st, startErr := h.Instances.Mgr.Start(cfg, logPath)
+		st, startErr := h.Instances.StartInstance(tgt.Server, manager.TriggerAPI)
 		return protocol.NewResponse(h.AgentID, msg.ID, st, startErr)
 
 	case protocol.CmdStop:
@@ -133,9 +225,48 @@ func (h *Handler) Handle(msg protocol.Message) (protocol.Message, error) {
 			return resp, nil
 		}
 
-		st, stopErr := h.Instances.Mgr.Stop(tgt.Server)
+		st, stopErr := h.Instances.StopInstance(tgt.Server, manager.TriggerAPI)
 		return protocol.NewResponse(h.AgentID, msg.ID, st, stopErr)
 
+	case protocol.CmdRestart:
+		return h.handleRestart(msg)
+
+	case protocol.CmdRestartRolling:
+		return h.handleRollingRestart(msg)
+
+	case protocol.CmdGroupStart, protocol.CmdGroupStop:
+		return h.handleGroup(msg)
+
+	case protocol.CmdMetrics:
+		return h.handleMetrics(msg)
+
+	// --------------------
+	// Maintenance
+	// --------------------
+	case protocol.CmdCordon, protocol.CmdUncordon, protocol.CmdDrain, protocol.CmdMaintenance:
+		return h.handleMaintenance(msg)
+
+	// --------------------
+	// Logs
+	// --------------------
+	case protocol.CmdLogs:
+		return h.handleLogs(msg)
+
+	case protocol.CmdLogsUnsubscribe:
+		return h.handleLogsUnsubscribe(msg)
+
+	// --------------------
+	// Console
+	// --------------------
+	case protocol.CmdConsoleSend:
+		return h.handleConsoleSend(msg)
+
+	// --------------------
+	// Schedules
+	// --------------------
+	case protocol.CmdSchedulesList:
+		return h.handleSchedulesList(msg)
+
 	default:
 		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown command type: %s", msg.Type))
 		return resp, nil
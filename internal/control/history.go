@@ -0,0 +1,59 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+const defaultHistoryLimit = 20
+
+func (h *Handler) handleInstancesHistory(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.InstanceHistoryRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+	if !h.Instances.HasInstance(req.Name) {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", req.Name))
+		return resp, nil
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	runs, err := h.Instances.Mgr.History(req.Name, limit)
+	if err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
+		return resp, nil
+	}
+
+	out := protocol.InstanceHistoryResponse{
+		Name: req.Name,
+		Runs: make([]protocol.RunHistoryEntry, 0, len(runs)),
+	}
+	for _, r := range runs {
+		out.Runs = append(out.Runs, protocol.RunHistoryEntry{
+			RunID:       r.RunID,
+			Trigger:     r.Trigger,
+			PID:         r.PID,
+			Adopted:     r.Adopted,
+			StartedAt:   r.StartedAt.UTC(),
+			EndedAt:     r.EndedAt.UTC(),
+			DurationMS:  r.EndedAt.Sub(r.StartedAt).Milliseconds(),
+			State:       string(r.State),
+			Reason:      r.Reason,
+			ExitCode:    r.ExitCode,
+			Signal:      r.Signal,
+			CoreDumped:  r.CoreDumped,
+			OOMKilled:   r.OOMKilled,
+			StopTrigger: r.StopTrigger,
+			StoppedBy:   r.StoppedBy,
+			LogTail:     r.LogTail,
+		})
+	}
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
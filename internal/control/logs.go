@@ -0,0 +1,136 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+const defaultLogTail = 100
+
+func (h *Handler) handleLogs(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.LogsRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+	if !h.Instances.HasInstance(req.Server) {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", req.Server))
+		return resp, nil
+	}
+	if req.Tail < 0 {
+		req.Tail = defaultLogTail
+	}
+
+	logPath := h.Instances.LogPath(req.Server)
+	lines, offset, err := manager.TailLog(logPath, req.Tail)
+	if err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("read log: %w", err))
+		return resp, nil
+	}
+
+	if req.Follow {
+		send := h.sender()
+		if send == nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("log streaming not available"))
+			return resp, nil
+		}
+		h.startLogStream(msg.ID, req.Server, logPath, offset, send)
+	}
+
+	return protocol.NewResponse(h.AgentID, msg.ID, protocol.LogsResponse{
+		Server: req.Server,
+		Lines:  lines,
+	}, nil)
+}
+
+func (h *Handler) handleLogsUnsubscribe(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.UnsubscribeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+
+	h.streamsMu.Lock()
+	cancel, ok := h.streams[req.ID]
+	h.streamsMu.Unlock()
+	if ok {
+		cancel()
+	}
+
+	return protocol.NewResponse(h.AgentID, msg.ID, map[string]any{
+		"ok": ok,
+	}, nil)
+}
+
+// startLogStream follows logPath from offset, pushing KindStream messages
+// tagged with id through send until unsubscribed or the connection goes away.
+func (h *Handler) startLogStream(id, server, logPath string, offset int64, send func(protocol.Message) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.streamsMu.Lock()
+	h.streams[id] = cancel
+	h.streamsMu.Unlock()
+
+	go func() {
+		defer func() {
+			h.streamsMu.Lock()
+			delete(h.streams, id)
+			h.streamsMu.Unlock()
+			cancel()
+		}()
+
+		// Reads end anywhere, so a character cut in two waits for its other half
+		var partial []byte
+		err := manager.FollowLog(ctx, logPath, offset, func(b []byte) error {
+			data, rest := splitUTF8(append(partial, b...))
+			partial = append([]byte(nil), rest...)
+			if len(data) == 0 {
+				return nil
+			}
+			m, err := protocol.NewStream(h.AgentID, id, protocol.LogChunk{Data: string(data)})
+			if err != nil {
+				return err
+			}
+			return send(m)
+		})
+
+		end := protocol.LogChunk{EOF: true}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[agent] log stream id=%s instance=%s ended: %v", id, server, err)
+			end.Error = err.Error()
+		}
+		if m, err := protocol.NewStream(h.AgentID, id, end); err == nil {
+			_ = send(m)
+		}
+	}()
+}
+
+// splitUTF8 splits b before a multi-byte character that is cut off at its
+// end. Invalid bytes are not held back.
+func splitUTF8(b []byte) (complete []byte, rest []byte) {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			return b[:i], b[i:]
+		}
+		break
+	}
+	return b, nil
+}
+
+// CloseStreams ends every active log subscription (e.g. on disconnect).
+func (h *Handler) CloseStreams() {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	for _, cancel := range h.streams {
+		cancel()
+	}
+}
@@ -0,0 +1,65 @@
+package control
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		complete string
+		rest     string
+	}{
+		{"empty", "", "", ""},
+		{"ascii", "hello\n", "hello\n", ""},
+		{"complete 2-byte", "caf\xc3\xa9", "caf\xc3\xa9", ""},
+		{"complete 3-byte", "1\xe2\x82\xac", "1\xe2\x82\xac", ""},
+		{"complete 4-byte", "go \xf0\x9f\x8e\xae", "go \xf0\x9f\x8e\xae", ""},
+		{"2-byte cut after 1", "caf\xc3", "caf", "\xc3"},
+		{"3-byte cut after 1", "1\xe2", "1", "\xe2"},
+		{"3-byte cut after 2", "1\xe2\x82", "1", "\xe2\x82"},
+		{"4-byte cut after 1", "go \xf0", "go ", "\xf0"},
+		{"4-byte cut after 2", "go \xf0\x9f", "go ", "\xf0\x9f"},
+		{"4-byte cut after 3", "go \xf0\x9f\x8e", "go ", "\xf0\x9f\x8e"},
+		{"only a cut rune", "\xf0\x9f\x8e", "", "\xf0\x9f\x8e"},
+		{"cut rune after a multi-byte one", "\xc3\xa9\xe2\x82", "\xc3\xa9", "\xe2\x82"},
+		{"invalid byte at end", "a\xff", "a\xff", ""},
+		{"stray continuation bytes", "a\x80\x80", "a\x80\x80", ""},
+		{"too many continuation bytes", "\xe2\x80\x80\x80\x80", "\xe2\x80\x80\x80\x80", ""},
+		{"lead byte followed by ascii", "\xf0a", "\xf0a", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete, rest := splitUTF8([]byte(tt.in))
+			if string(complete) != tt.complete || string(rest) != tt.rest {
+				t.Errorf("splitUTF8(%q) = %q, %q, want %q, %q", tt.in, complete, rest, tt.complete, tt.rest)
+			}
+		})
+	}
+}
+
+// Carrying the rest into the next chunk, as the log stream does, never
+// splits a character and loses nothing.
+func TestSplitUTF8Carry(t *testing.T) {
+	text := []byte("héllo wörld ✓ 🎮 日本語\n")
+	for size := 1; size <= 8; size++ {
+		var out, partial []byte
+		for i := 0; i < len(text); i += size {
+			chunk := append(partial, text[i:min(i+size, len(text))]...)
+			var complete []byte
+			complete, partial = splitUTF8(chunk)
+			if !utf8.Valid(complete) {
+				t.Errorf("chunks of %d: sent %q, which splits a character", size, complete)
+			}
+			out = append(out, complete...)
+			partial = append([]byte{}, partial...)
+		}
+		out = append(out, partial...)
+		if !bytes.Equal(out, text) {
+			t.Errorf("chunks of %d: got %q, want %q", size, out, text)
+		}
+	}
+}
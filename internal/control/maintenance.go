@@ -0,0 +1,84 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/instances"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+// startsWork reports whether a command would start or create instances,
+// which a cordoned agent refuses.
+func startsWork(cmdType string) bool {
+	switch cmdType {
+	case protocol.CmdStart, protocol.CmdRestart, protocol.CmdRestartRolling,
+		protocol.CmdGroupStart, protocol.CmdInstancesCreate:
+		return true
+	}
+	return false
+}
+
+func (h *Handler) handleMaintenance(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.CordonRequest
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+			return resp, nil
+		}
+	}
+
+	var m instances.Maintenance
+	var err error
+	switch msg.Type {
+	case protocol.CmdCordon:
+		m = h.Instances.Cordon(req.Reason)
+	case protocol.CmdUncordon:
+		m, err = h.Instances.Uncordon()
+	case protocol.CmdDrain:
+		m, err = h.Instances.Drain(req.Reason, "drain")
+	default:
+		m = h.Instances.Maintenance()
+	}
+	if err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, err)
+		return resp, nil
+	}
+
+	if msg.Type != protocol.CmdMaintenance {
+		h.InstanceListChanged()
+	}
+	return protocol.NewResponse(h.AgentID, msg.ID, toMaintenanceStatus(m), nil)
+}
+
+func toMaintenanceStatus(m instances.Maintenance) protocol.MaintenanceStatus {
+	out := protocol.MaintenanceStatus{Cordoned: m.Cordoned, Reason: m.Reason, Since: m.Since}
+	if m.Drain == nil {
+		return out
+	}
+
+	d := &protocol.DrainStatus{
+		Done:       m.Drain.Done(),
+		StartedAt:  m.Drain.StartedAt,
+		FinishedAt: m.Drain.FinishedAt,
+		Results:    make([]protocol.DrainResult, 0, len(m.Drain.Results)),
+	}
+	for _, r := range m.Drain.Results {
+		switch r.Outcome {
+		case instances.DrainStopped:
+			d.Stopped++
+		case instances.DrainFailed:
+			d.Failed++
+		default:
+			d.Remaining++
+		}
+		d.Results = append(d.Results, protocol.DrainResult{
+			Instance: r.Instance,
+			Outcome:  r.Outcome,
+			State:    string(r.State),
+			Error:    r.Error,
+		})
+	}
+	out.Drain = d
+	return out
+}
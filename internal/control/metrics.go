@@ -0,0 +1,51 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+func (h *Handler) handleMetrics(msg protocol.Message) (protocol.Message, error) {
+	var tgt protocol.ServerTarget
+	if err := json.Unmarshal(msg.Payload, &tgt); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+	if !h.Instances.HasInstance(tgt.Server) {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", tgt.Server))
+		return resp, nil
+	}
+
+	cur, history := h.Instances.Mgr.Metrics(tgt.Server)
+
+	out := protocol.MetricsResponse{
+		Server:  tgt.Server,
+		Samples: make([]protocol.MetricsSample, 0, len(history)),
+	}
+	if cur != nil {
+		s := toMetricsSample(*cur)
+		out.Current = &s
+	}
+	for _, m := range history {
+		out.Samples = append(out.Samples, toMetricsSample(m))
+	}
+
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
+
+func toMetricsSample(m manager.ProcessMetrics) protocol.MetricsSample {
+	return protocol.MetricsSample{
+		TS:            m.Time.UTC(),
+		CPUPercent:    m.CPUPercent,
+		RSSBytes:      m.RSSBytes,
+		Threads:       m.Threads,
+		OpenFDs:       m.OpenFDs,
+		ReadBytes:     m.ReadBytes,
+		WriteBytes:    m.WriteBytes,
+		Processes:     m.Processes,
+		UptimeSeconds: int64(m.Uptime.Seconds()),
+	}
+}
@@ -0,0 +1,86 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+const defaultRollingTimeout = 5 * time.Minute
+
+func (h *Handler) handleRestart(msg protocol.Message) (protocol.Message, error) {
+	var tgt protocol.ServerTarget
+	if err := json.Unmarshal(msg.Payload, &tgt); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+
+	st, err := h.Instances.RestartInstance(tgt.Server, manager.TriggerAPI)
+	return protocol.NewResponse(h.AgentID, msg.ID, st, err)
+}
+
+func (h *Handler) handleRollingRestart(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.RollingRestartRequest
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+			return resp, nil
+		}
+	}
+
+	servers := req.Servers
+	if len(servers) == 0 {
+		for _, name := range h.Instances.ListInstanceNames() {
+			if h.Instances.Mgr.IsRunning(name) {
+				servers = append(servers, name)
+			}
+		}
+		sort.Strings(servers)
+	}
+	for _, name := range servers {
+		if !h.Instances.HasInstance(name) {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", name))
+			return resp, nil
+		}
+	}
+
+	timeout := defaultRollingTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+
+	out := protocol.RollingRestartResponse{OK: true, Results: []protocol.RollingRestartResult{}}
+	for _, name := range servers {
+		if !out.OK && !req.ContinueOnError {
+			out.Results = append(out.Results, protocol.RollingRestartResult{Server: name, Skipped: true})
+			continue
+		}
+
+		started := time.Now()
+		res := protocol.RollingRestartResult{Server: name}
+
+		st, err := h.Instances.RestartInstance(name, "rolling-restart")
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			st, err = h.Instances.WaitReady(ctx, name)
+			cancel()
+		}
+
+		res.State = string(st.State)
+		res.DurationMS = time.Since(started).Milliseconds()
+		if err != nil {
+			res.Error = err.Error()
+			out.OK = false
+		} else {
+			res.OK = true
+		}
+		out.Results = append(out.Results, res)
+	}
+
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
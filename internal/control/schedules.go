@@ -0,0 +1,57 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+func (h *Handler) handleSchedulesList(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.SchedulesRequest
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+			return resp, nil
+		}
+	}
+	if req.Instance != "" && !h.Instances.HasInstance(req.Instance) {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown instance: %s", req.Instance))
+		return resp, nil
+	}
+
+	out := protocol.SchedulesResponse{Schedules: []protocol.ScheduleStatus{}}
+	if h.Instances.Scheduler != nil {
+		for _, st := range h.Instances.Scheduler.List(req.Instance) {
+			out.Schedules = append(out.Schedules, protocol.ScheduleStatus{
+				Instance:       st.Instance,
+				Name:           st.Name,
+				Cron:           st.Cron,
+				Action:         string(st.Action),
+				Command:        st.Command,
+				NextRun:        st.Next,
+				Running:        st.Running,
+				LastRun:        st.LastRun,
+				LastDurationMS: st.LastDuration.Milliseconds(),
+				LastResult:     st.LastResult,
+				LastError:      st.LastError,
+			})
+		}
+	}
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
+
+func toConfigSchedules(in []protocol.ScheduleSpec) []config.Schedule {
+	out := make([]config.Schedule, 0, len(in))
+	for _, s := range in {
+		out = append(out, config.Schedule{
+			Name:    s.Name,
+			Cron:    s.Cron,
+			Action:  s.Action,
+			Command: s.Command,
+			Timeout: s.Timeout,
+		})
+	}
+	return out
+}
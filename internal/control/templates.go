@@ -0,0 +1,64 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/protocol"
+)
+
+func (h *Handler) handleTemplatesList(msg protocol.Message) (protocol.Message, error) {
+	out := protocol.TemplatesResponse{Templates: []protocol.TemplateInfo{}}
+	for _, name := range h.Instances.TemplateNames() {
+		tpl, _ := h.Instances.Template(name)
+		out.Templates = append(out.Templates, toTemplateInfo(name, tpl))
+	}
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
+
+func (h *Handler) handleTemplatesDescribe(msg protocol.Message) (protocol.Message, error) {
+	var req protocol.TemplateRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("bad payload: %w", err))
+		return resp, nil
+	}
+
+	tpl, ok := h.Instances.Template(req.Template)
+	if !ok {
+		resp, _ := protocol.NewResponse(h.AgentID, msg.ID, nil, fmt.Errorf("unknown template: %s", req.Template))
+		return resp, nil
+	}
+
+	out := toTemplateInfo(req.Template, tpl)
+	out.Command = tpl.Command
+	out.Args = tpl.Args
+	out.Cwd = tpl.Cwd
+	out.Env = tpl.Env
+	out.Instances = h.Instances.InstancesOf(req.Template)
+	return protocol.NewResponse(h.AgentID, msg.ID, out, nil)
+}
+
+func toTemplateInfo(name string, tpl config.Template) protocol.TemplateInfo {
+	out := protocol.TemplateInfo{
+		Name:        name,
+		Description: tpl.Description,
+		Params:      make([]protocol.TemplateParam, 0, len(tpl.Params)),
+	}
+	for _, p := range tpl.Params {
+		typ := p.Type
+		if typ == "" {
+			typ = config.ParamString
+		}
+		out.Params = append(out.Params, protocol.TemplateParam{
+			Name:        p.Name,
+			Type:        string(typ),
+			Required:    p.Required,
+			Default:     p.Default,
+			Values:      p.Values,
+			Pattern:     p.Pattern,
+			Description: p.Description,
+		})
+	}
+	return out
+}
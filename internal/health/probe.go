@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// Probe runs a tcp or http health check; the agent hands it to the manager
+// with SetNetworkProbe. ctx carries the check's timeout.
+func Probe(ctx context.Context, hc manager.HealthCheck) error {
+	switch hc.Type {
+	case manager.HealthTCP:
+		var d net.Dialer
+		c, err := d.DialContext(ctx, "tcp", hc.Address)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+
+	case manager.HealthHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.URL, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = res.Body.Close()
+		if res.StatusCode >= 400 {
+			return fmt.Errorf("http status %d", res.StatusCode)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("not a network health check: %q", hc.Type)
+	}
+}
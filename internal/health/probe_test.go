@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+func TestProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	// A port that was just free, so nothing listens on it
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		hc      manager.HealthCheck
+		wantErr bool
+	}{
+		{"tcp open", manager.HealthCheck{Type: manager.HealthTCP, Address: ln.Addr().String()}, false},
+		{"tcp closed", manager.HealthCheck{Type: manager.HealthTCP, Address: closedAddr}, true},
+		{"http ok", manager.HealthCheck{Type: manager.HealthHTTP, URL: srv.URL + "/ok"}, false},
+		{"http redirect", manager.HealthCheck{Type: manager.HealthHTTP, URL: srv.URL + "/moved"}, false},
+		{"http error status", manager.HealthCheck{Type: manager.HealthHTTP, URL: srv.URL + "/down"}, true},
+		{"http bad url", manager.HealthCheck{Type: manager.HealthHTTP, URL: "://nope"}, true},
+		{"not a network check", manager.HealthCheck{Type: manager.HealthExec, Command: "true"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := Probe(ctx, tt.hc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Probe = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
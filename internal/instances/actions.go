@@ -0,0 +1,266 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/schedule"
+)
+
+// dependencyTimeout bounds the wait for a dependency to become ready.
+const dependencyTimeout = 5 * time.Minute
+
+// StartInstance resolves an instance's config and starts it, after starting
+// any of its dependencies that are down and waiting for them to be ready.
+// trigger is recorded in the run history.
+func (s *Service) StartInstance(name string, trigger string) (manager.ServerState, error) {
+	cfg, logPath, err := s.ResolveConfig(name)
+	if err != nil {
+		return manager.ServerState{}, err
+	}
+	if err := s.startDependencies(name, trigger); err != nil {
+		return s.Mgr.Status(name), err
+	}
+	return s.Mgr.Start(cfg, logPath, trigger)
+}
+
+// StopInstance stops an instance, after stopping the running instances that
+// depend on it (theirs first).
+func (s *Service) StopInstance(name string, trigger string) (manager.ServerState, error) {
+	if active(s.Mgr.Status(name)) {
+		if _, err := s.stopDependents(name, trigger); err != nil {
+			return s.Mgr.Status(name), err
+		}
+	}
+	return s.Mgr.Stop(name, trigger)
+}
+
+// RestartInstance re-resolves an instance's config (so param and template
+// changes take effect), stops it if it is running, waiting for it to exit,
+// and starts it again. Running dependents are stopped first and started
+// again once it is ready; dependencies that are down are started. A config
+// that no longer resolves leaves the running process alone. If the restart
+// fails partway, the dependents already stopped are still started again,
+// and the error names every instance left down.
+func (s *Service) RestartInstance(name string, trigger string) (manager.ServerState, error) {
+	cfg, logPath, err := s.ResolveConfig(name)
+	if err != nil {
+		return manager.ServerState{}, err
+	}
+
+	stopped, err := s.stopDependents(name, trigger)
+	if err != nil {
+		return s.Mgr.Status(name), s.restartDependents(name, stopped, trigger, err)
+	}
+	if active(s.Mgr.Status(name)) {
+		if _, err := s.Mgr.Stop(name, trigger); err != nil {
+			return s.Mgr.Status(name), s.restartDependents(name, stopped, trigger, fmt.Errorf("stop: %w", err))
+		}
+	}
+	if err := s.startDependencies(name, trigger); err != nil {
+		return s.Mgr.Status(name), s.restartDependents(name, stopped, trigger, err)
+	}
+
+	st, err := s.Mgr.Start(cfg, logPath, trigger)
+	if len(stopped) == 0 {
+		return st, err
+	}
+	if err == nil {
+		// Dependents start once it is ready
+		if err = s.ensureReady(name, trigger); err != nil {
+			err = fmt.Errorf("restarting %s: %w", name, err)
+		}
+	}
+	return s.Mgr.Status(name), s.restartDependents(name, stopped, trigger, err)
+}
+
+// restartDependents starts the dependents stopped for a restart of name
+// again, in start order, and returns the first error of the restart (cause,
+// if set) with the instances it leaves down.
+func (s *Service) restartDependents(name string, stopped []string, trigger string, cause error) error {
+	var down []string
+	if cause != nil && !active(s.Mgr.Status(name)) {
+		down = append(down, name)
+	}
+	for _, dep := range stopped {
+		if err := s.ensureReady(dep, trigger); err != nil {
+			if cause == nil {
+				cause = fmt.Errorf("restarting dependent %s: %w", dep, err)
+			}
+			if !active(s.Mgr.Status(dep)) {
+				down = append(down, dep)
+			}
+		}
+	}
+	if cause != nil && len(down) > 0 {
+		return fmt.Errorf("%w (left down: %s)", cause, strings.Join(down, ", "))
+	}
+	return cause
+}
+
+// active reports whether an instance is running or about to be (queued or
+// waiting for an automatic restart).
+func active(st manager.ServerState) bool {
+	return st.Running || st.State == manager.StateBackoff || st.State == manager.StateQueued
+}
+
+// startDependencies brings up everything name depends on, in order.
+func (s *Service) startDependencies(name string, trigger string) error {
+	s.mu.Lock()
+	order, err := config.DependencyOrder(s.Instances, []string{name})
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range order[:len(order)-1] {
+		if err := s.ensureReady(dep, trigger); err != nil {
+			return fmt.Errorf("dependency %s: %w", dep, err)
+		}
+	}
+	return nil
+}
+
+// stopDependents stops the running instances that depend on name,
+// dependents of dependents first. It returns the ones it stopped, in start
+// order.
+func (s *Service) stopDependents(name string, trigger string) ([]string, error) {
+	s.mu.Lock()
+	dependents := config.Dependents(s.Instances, name)
+	s.mu.Unlock()
+
+	var stopped []string
+	for i := len(dependents) - 1; i >= 0; i-- {
+		dep := dependents[i]
+		if !active(s.Mgr.Status(dep)) {
+			continue
+		}
+		if _, err := s.Mgr.Stop(dep, trigger); err != nil {
+			return stopped, fmt.Errorf("stopping dependent %s: %w", dep, err)
+		}
+		stopped = append([]string{dep}, stopped...)
+	}
+	return stopped, nil
+}
+
+// ensureReady starts an instance unless it is already up or on its way,
+// then waits for it to be ready.
+func (s *Service) ensureReady(name string, trigger string) error {
+	switch s.Mgr.Status(name).State {
+	case manager.StateRunning, manager.StateStarting, manager.StateQueued:
+	default:
+		cfg, logPath, err := s.ResolveConfig(name)
+		if err != nil {
+			return err
+		}
+		if _, err := s.Mgr.Start(cfg, logPath, trigger); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyTimeout)
+	defer cancel()
+	_, err := s.WaitReady(ctx, name)
+	return err
+}
+
+// WaitReady waits until an instance reaches the running state, which for
+// templates with a health check means the check has passed. It fails as soon
+// as the instance ends up in any state other than queued or starting.
+func (s *Service) WaitReady(ctx context.Context, name string) (manager.ServerState, error) {
+	t := time.NewTicker(250 * time.Millisecond)
+	defer t.Stop()
+
+	for {
+		st := s.Mgr.Status(name)
+		switch st.State {
+		case manager.StateRunning:
+			return st, nil
+		case manager.StateQueued, manager.StateStarting:
+		default:
+			return st, fmt.Errorf("%s is %s: %s", name, st.State, st.StateReason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return s.Mgr.Status(name), fmt.Errorf("%s not ready: %w", name, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// LoadSchedules registers the schedules of every configured instance.
+func (s *Service) LoadSchedules() error {
+	if s.Scheduler == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, inst := range s.Instances {
+		entries, err := config.ConvertSchedules(name, inst.Schedules)
+		if err != nil {
+			return err
+		}
+		s.Scheduler.Set(name, entries)
+	}
+	return nil
+}
+
+// RunSchedule executes a scheduled entry; it is the scheduler's RunFunc.
+func (s *Service) RunSchedule(ctx context.Context, e schedule.Entry) error {
+	trigger := "schedule:" + e.Name
+	switch e.Action {
+	case schedule.ActionStart:
+		if err := s.CheckCordon(); err != nil {
+			return err
+		}
+		_, err := s.StartInstance(e.Instance, trigger)
+		return err
+	case schedule.ActionStop:
+		_, err := s.StopInstance(e.Instance, trigger)
+		return err
+	case schedule.ActionRestart:
+		if err := s.CheckCordon(); err != nil {
+			return err
+		}
+		_, err := s.RestartInstance(e.Instance, trigger)
+		return err
+	case schedule.ActionStdin:
+		return s.Mgr.SendInput(e.Instance, e.Command)
+	case schedule.ActionHook:
+		return s.runScheduledHook(e)
+	}
+	return fmt.Errorf("unknown action %q", e.Action)
+}
+
+// runScheduledHook runs the hook in the instance directory, even while the
+// instance is stopped or disabled (e.g. offline backups).
+func (s *Service) runScheduledHook(e schedule.Entry) error {
+	s.mu.Lock()
+	inst, ok := s.Instances[e.Instance]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown instance: %s", e.Instance)
+	}
+
+	cfg, logPath, err := s.resolve(e.Instance, inst)
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), cfg.Env...)
+	env = append(env,
+		"RPM_INSTANCE="+e.Instance,
+		"RPM_SCHEDULE="+e.Name,
+		"RPM_LOG_PATH="+logPath,
+	)
+	hook := manager.Hook{Command: e.Command, Timeout: e.Timeout}
+	return manager.RunHook(hook, cfg.RunAs, s.InstanceDir(e.Instance), env, logPath, "schedule "+e.Name)
+}
@@ -0,0 +1,174 @@
+package instances
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// Autostart outcomes
+const (
+	AutostartPending  = "pending"         // waiting for its delay or its turn
+	AutostartStarted  = "started"         // start issued (the instance may still be queued or starting)
+	AutostartRunning  = "already-running" // re-adopted or started by someone else meanwhile
+	AutostartDisabled = "disabled"        // autostart set but the instance is disabled
+	AutostartCordoned = "cordoned"        // not started: the agent was cordoned meanwhile
+	AutostartFailed   = "failed"
+)
+
+// AutostartResult is what autostart did with one instance.
+type AutostartResult struct {
+	Instance string
+	Outcome  string
+	State    manager.LifecycleState
+	Error    string
+	At       time.Time
+}
+
+type autostartReport struct {
+	mu      sync.Mutex
+	results []AutostartResult
+}
+
+// Autostart starts every instance with autostart enabled, highest priority
+// first (ties by name) but never before its dependencies, each after its own
+// delay; dependencies without autostart are started as needed. It runs once
+// at agent boot and does not depend on the command server; report is called
+// after every outcome so the agent can pass them on.
+func (s *Service) Autostart(ctx context.Context, report func()) {
+	type planned struct {
+		name  string
+		inst  config.Instance
+		delay time.Duration
+	}
+
+	s.mu.Lock()
+	var plan []planned
+	for name, inst := range s.Instances {
+		if !inst.Autostart.Enabled {
+			continue
+		}
+		delay, _ := config.AutostartDelay(name, inst.Autostart) // validated on load and create
+		plan = append(plan, planned{name: name, inst: inst, delay: delay})
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		if plan[i].inst.Autostart.Priority != plan[j].inst.Autostart.Priority {
+			return plan[i].inst.Autostart.Priority > plan[j].inst.Autostart.Priority
+		}
+		return plan[i].name < plan[j].name
+	})
+
+	// Dependencies go first whatever their priority (validated on load)
+	byName := map[string]planned{}
+	names := make([]string, 0, len(plan))
+	for _, p := range plan {
+		byName[p.name] = p
+		names = append(names, p.name)
+	}
+	order, _ := config.DependencyOrder(s.Instances, names)
+	s.mu.Unlock()
+
+	plan = plan[:0]
+	for _, name := range order {
+		if p, ok := byName[name]; ok {
+			plan = append(plan, p)
+		}
+	}
+
+	s.autostart.mu.Lock()
+	s.autostart.results = make([]AutostartResult, len(plan))
+	for i, p := range plan {
+		s.autostart.results[i] = AutostartResult{Instance: p.name, Outcome: AutostartPending}
+	}
+	s.autostart.mu.Unlock()
+
+	for i, p := range plan {
+		res := s.autostartOne(ctx, p.name, p.inst, p.delay)
+		if ctx.Err() != nil {
+			return
+		}
+		if res.Error != "" {
+			log.Printf("[agent] autostart %s: %s: %s", p.name, res.Outcome, res.Error)
+		} else {
+			log.Printf("[agent] autostart %s: %s", p.name, res.Outcome)
+		}
+
+		s.autostart.mu.Lock()
+		s.autostart.results[i] = res
+		s.autostart.mu.Unlock()
+		if report != nil {
+			report()
+		}
+	}
+}
+
+func (s *Service) autostartOne(ctx context.Context, name string, inst config.Instance, delay time.Duration) AutostartResult {
+	res := AutostartResult{Instance: name}
+
+	if !inst.Enabled {
+		res.Outcome = AutostartDisabled
+		res.At = time.Now()
+		return res
+	}
+
+	if st := s.Mgr.Status(name); !autostartable(st.State) {
+		res.Outcome = AutostartRunning
+		res.State = st.State
+		res.At = time.Now()
+		return res
+	}
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return res
+		case <-time.After(delay):
+		}
+		// A start can come in through the API while we wait
+		if st := s.Mgr.Status(name); !autostartable(st.State) {
+			res.Outcome = AutostartRunning
+			res.State = st.State
+			res.At = time.Now()
+			return res
+		}
+	}
+
+	if err := s.CheckCordon(); err != nil {
+		res.Outcome = AutostartCordoned
+		res.Error = err.Error()
+		res.At = time.Now()
+		return res
+	}
+
+	st, err := s.StartInstance(name, "autostart")
+	res.State = st.State
+	res.At = time.Now()
+	if err != nil {
+		res.Outcome = AutostartFailed
+		res.Error = err.Error()
+		return res
+	}
+	res.Outcome = AutostartStarted
+	return res
+}
+
+// autostartable reports whether autostart should start an instance that is
+// in state st: only if nothing has started it yet.
+func autostartable(st manager.LifecycleState) bool {
+	return st == "" || st == manager.StateStopped
+}
+
+// AutostartResults returns the outcome of every autostart instance, in start
+// order. It is empty until Autostart has run.
+func (s *Service) AutostartResults() []AutostartResult {
+	s.autostart.mu.Lock()
+	defer s.autostart.mu.Unlock()
+
+	return append([]AutostartResult(nil), s.autostart.results...)
+}
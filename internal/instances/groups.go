@@ -0,0 +1,87 @@
+package instances
+
+import (
+	"fmt"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// GroupResult is what a group operation did with one instance.
+type GroupResult struct {
+	Instance string
+	State    manager.LifecycleState
+	Skipped  bool // not attempted after an earlier failure
+	Err      error
+}
+
+// StartGroup starts the members of a group, and whatever they depend on, in
+// dependency order, waiting for each to be ready before the next. Instances
+// that are already up are left alone. It stops at the first failure.
+func (s *Service) StartGroup(group string, trigger string) ([]GroupResult, error) {
+	s.mu.Lock()
+	members := config.GroupMembers(s.Instances, group)
+	order, err := config.DependencyOrder(s.Instances, members)
+	s.mu.Unlock()
+	if len(members) == 0 {
+		return nil, fmt.Errorf("unknown group: %s", group)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]GroupResult, 0, len(order))
+	failed := false
+	for _, name := range order {
+		if failed {
+			results = append(results, GroupResult{Instance: name, Skipped: true})
+			continue
+		}
+		err := s.ensureReady(name, trigger)
+		results = append(results, GroupResult{Instance: name, State: s.Mgr.Status(name).State, Err: err})
+		failed = err != nil
+	}
+	return results, nil
+}
+
+// StopGroup stops the members of a group in reverse dependency order.
+// Instances outside the group that depend on a member are stopped first,
+// as a single stop would. A failed stop doesn't keep the others running.
+func (s *Service) StopGroup(group string, trigger string) ([]GroupResult, error) {
+	s.mu.Lock()
+	members := config.GroupMembers(s.Instances, group)
+	stop := map[string]bool{}
+	for _, name := range members {
+		stop[name] = true
+		for _, dep := range config.Dependents(s.Instances, name) {
+			stop[dep] = true
+		}
+	}
+	names := make([]string, 0, len(stop))
+	for name := range stop {
+		names = append(names, name)
+	}
+	order, err := config.DependencyOrder(s.Instances, names)
+	s.mu.Unlock()
+	if len(members) == 0 {
+		return nil, fmt.Errorf("unknown group: %s", group)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []GroupResult
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		if !stop[name] {
+			continue // a dependency outside the group
+		}
+		res := GroupResult{Instance: name}
+		if active(s.Mgr.Status(name)) {
+			_, res.Err = s.Mgr.Stop(name, trigger)
+		}
+		res.State = s.Mgr.Status(name).State
+		results = append(results, res)
+	}
+	return results, nil
+}
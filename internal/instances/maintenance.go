@@ -0,0 +1,259 @@
+package instances
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faradayfan/remote-process-manager/internal/config"
+	"github.com/faradayfan/remote-process-manager/internal/manager"
+)
+
+// Drain outcomes
+const (
+	DrainPending  = "pending"  // waiting for the instances that depend on it
+	DrainStopping = "stopping" // stop chain running
+	DrainStopped  = "stopped"
+	DrainFailed   = "failed"
+)
+
+// Maintenance is the agent's maintenance state. While cordoned, nothing new
+// is started or created on the agent; a drain additionally stops everything
+// that runs.
+type Maintenance struct {
+	Cordoned bool
+	Reason   string
+	Since    time.Time
+	Drain    *DrainStatus // the last drain, nil if there was none
+}
+
+// DrainStatus is the progress of a drain.
+type DrainStatus struct {
+	StartedAt  time.Time
+	FinishedAt time.Time // zero while in progress
+	Results    []DrainResult
+}
+
+// Done reports whether the drain has finished.
+func (d *DrainStatus) Done() bool { return !d.FinishedAt.IsZero() }
+
+// DrainResult is what a drain did with one instance.
+type DrainResult struct {
+	Instance string
+	Outcome  string
+	State    manager.LifecycleState
+	Error    string
+}
+
+type maintenanceState struct {
+	mu       sync.Mutex
+	cordoned bool
+	reason   string
+	since    time.Time
+	drain    *DrainStatus
+}
+
+// Cordon stops new work from landing on the agent: starts (including
+// restarts, group starts, scheduled starts and autostart) and creates are
+// refused until Uncordon, and the restart policy and health checks don't
+// restart anything. Running instances are left alone. Cordoning again with a
+// reason updates it.
+func (s *Service) Cordon(reason string) Maintenance {
+	s.maint.mu.Lock()
+	if !s.maint.cordoned {
+		s.maint.cordoned = true
+		s.maint.since = time.Now()
+	}
+	if reason != "" {
+		s.maint.reason = reason
+	}
+	reason = s.maint.reason
+	s.maint.mu.Unlock()
+	s.Mgr.SetCordoned(true)
+
+	log.Printf("[agent] cordoned: %s", orNone(reason))
+	return s.Maintenance()
+}
+
+// Uncordon lets new work land on the agent again. It refuses while a drain
+// is in progress.
+func (s *Service) Uncordon() (Maintenance, error) {
+	s.maint.mu.Lock()
+	if d := s.maint.drain; d != nil && !d.Done() {
+		s.maint.mu.Unlock()
+		return s.Maintenance(), fmt.Errorf("a drain is in progress; uncordon once it has finished")
+	}
+	wasCordoned := s.maint.cordoned
+	s.maint.cordoned = false
+	s.maint.reason = ""
+	s.maint.since = time.Time{}
+	s.maint.mu.Unlock()
+	s.Mgr.SetCordoned(false)
+
+	if wasCordoned {
+		log.Printf("[agent] uncordoned")
+	}
+	return s.Maintenance(), nil
+}
+
+// Maintenance returns the current maintenance state.
+func (s *Service) Maintenance() Maintenance {
+	s.maint.mu.Lock()
+	defer s.maint.mu.Unlock()
+
+	m := Maintenance{
+		Cordoned: s.maint.cordoned,
+		Reason:   s.maint.reason,
+		Since:    s.maint.since,
+	}
+	if d := s.maint.drain; d != nil {
+		cp := *d
+		cp.Results = append([]DrainResult(nil), d.Results...)
+		m.Drain = &cp
+	}
+	return m
+}
+
+// CheckCordon returns an error if the agent is cordoned.
+func (s *Service) CheckCordon() error {
+	s.maint.mu.Lock()
+	defer s.maint.mu.Unlock()
+
+	if !s.maint.cordoned {
+		return nil
+	}
+	if s.maint.reason != "" {
+		return fmt.Errorf("agent is cordoned (%s): starts and creates are refused until it is uncordoned", s.maint.reason)
+	}
+	return fmt.Errorf("agent is cordoned: starts and creates are refused until it is uncordoned")
+}
+
+// Drain cordons the agent and stops every running instance in the
+// background, each with its own stop chain and only once the instances
+// that depend on it are down; independent instances stop in parallel.
+// Pending starts are called off: queued ones and pending restarts are
+// cancelled, and one in its pre_start hooks is cancelled once they finish. A
+// failed stop doesn't hold up instances that don't depend on it. Progress is
+// in Maintenance.
+func (s *Service) Drain(reason string, trigger string) (Maintenance, error) {
+	d := &DrainStatus{StartedAt: time.Now()}
+	s.maint.mu.Lock()
+	if cur := s.maint.drain; cur != nil && !cur.Done() {
+		s.maint.mu.Unlock()
+		return s.Maintenance(), fmt.Errorf("a drain is already in progress")
+	}
+	s.maint.drain = d
+	s.maint.mu.Unlock()
+
+	// Cordoned from here on, so the set of active instances can only shrink
+	s.Cordon(reason)
+
+	var names []string
+	for _, name := range s.ListInstanceNames() {
+		if active(s.Mgr.Status(name)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	order, err := config.DependencyOrder(s.Instances, names)
+	dependents := map[string][]string{}
+	for _, name := range names {
+		dependents[name] = config.Dependents(s.Instances, name)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.maint.mu.Lock()
+		d.FinishedAt = time.Now()
+		s.maint.mu.Unlock()
+		return s.Maintenance(), err
+	}
+
+	// Dependencies pulled in by the ordering are left out if they were down
+	drain := map[string]bool{}
+	for _, name := range names {
+		drain[name] = true
+	}
+	index := map[string]int{}
+	s.maint.mu.Lock()
+	for i := len(order) - 1; i >= 0; i-- {
+		if name := order[i]; drain[name] {
+			index[name] = len(d.Results)
+			d.Results = append(d.Results, DrainResult{Instance: name, Outcome: DrainPending})
+		}
+	}
+	s.maint.mu.Unlock()
+	log.Printf("[agent] draining %d instance(s)", len(names))
+
+	update := func(name string, outcome string, st manager.ServerState, err error) {
+		s.maint.mu.Lock()
+		defer s.maint.mu.Unlock()
+		r := &d.Results[index[name]]
+		r.Outcome = outcome
+		r.State = st.State
+		if err != nil {
+			r.Error = err.Error()
+		}
+	}
+
+	stopped := map[string]chan struct{}{}
+	for name := range drain {
+		stopped[name] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
+	for name := range drain {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer close(stopped[name])
+			for _, dep := range dependents[name] {
+				if ch, ok := stopped[dep]; ok {
+					<-ch
+				}
+			}
+
+			st := s.Mgr.Status(name)
+			var err error
+			if active(st) {
+				update(name, DrainStopping, st, nil)
+				st, err = s.Mgr.Stop(name, trigger)
+			}
+			if err != nil && active(s.Mgr.Status(name)) {
+				update(name, DrainFailed, st, err)
+				log.Printf("[agent] drain %s: %v", name, err)
+				return
+			}
+			// An instance that went down by itself meanwhile counts as stopped
+			update(name, DrainStopped, s.Mgr.Status(name), nil)
+		}(name)
+	}
+
+	go func() {
+		wg.Wait()
+
+		s.maint.mu.Lock()
+		d.FinishedAt = time.Now()
+		failed := 0
+		for _, r := range d.Results {
+			if r.Outcome == DrainFailed {
+				failed++
+			}
+		}
+		total := len(d.Results)
+		s.maint.mu.Unlock()
+
+		log.Printf("[agent] drain finished: %d stopped, %d failed", total-failed, failed)
+	}()
+
+	return s.Maintenance(), nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "no reason given"
+	}
+	return s
+}
@@ -2,14 +2,20 @@ package instances
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"text/template"
 
 	"github.com/faradayfan/remote-process-manager/internal/config"
 	"github.com/faradayfan/remote-process-manager/internal/manager"
+	"github.com/faradayfan/remote-process-manager/internal/schedule"
 )
 
 type Service struct {
@@ -21,6 +27,15 @@ type Service struct {
 
 	Store *Store
 
+	// Scheduler runs instance schedules; nil disables them
+	Scheduler *schedule.Scheduler
+
+	// Outcomes of the boot-time autostart (see Autostart)
+	autostart autostartReport
+
+	// Cordon and drain state (see Cordon)
+	maint maintenanceState
+
 	BaseInstanceDir string
 	LogDir          string
 }
@@ -61,20 +76,96 @@ func (s *Service) ListInstanceNames() []string {
 	return out
 }
 
-func (s *Service) ListInstanceSummaries() []map[string]any {
+// TemplateNames returns the names of the templates, sorted.
+func (s *Service) TemplateNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]string, 0, len(s.Templates))
+	for name := range s.Templates {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *Service) Template(name string) (config.Template, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tpl, ok := s.Templates[name]
+	return tpl, ok
+}
+
+// InstancesOf returns the instances created from a template, sorted.
+func (s *Service) InstancesOf(template string) []string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	out := make([]map[string]any, 0, len(s.Instances))
+	var out []string
 	for name, inst := range s.Instances {
+		if inst.Template == template {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *Service) HasInstance(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.Instances[name]
+	return ok
+}
+
+// ListInstanceSummaries describes every instance and its state. The manager
+// is queried after s.mu is released, as its status reads log segments from
+// disk.
+func (s *Service) ListInstanceSummaries() []map[string]any {
+	s.mu.Lock()
+	insts := make(map[string]config.Instance, len(s.Instances))
+	for name, inst := range s.Instances {
+		insts[name] = inst
+	}
+	s.mu.Unlock()
+
+	out := make([]map[string]any, 0, len(insts))
+	for name, inst := range insts {
 		st := s.Mgr.Status(name)
 		out = append(out, map[string]any{
-			"name":     name,
-			"template": inst.Template,
-			"enabled":  inst.Enabled,
-			"params":   inst.Params,
-			"running":  st.Running,
-			"pid":      st.PID,
+			"name":           name,
+			"template":       inst.Template,
+			"enabled":        inst.Enabled,
+			"autostart":      inst.Autostart.Enabled,
+			"depends_on":     inst.DependsOn,
+			"group":          inst.Group,
+			"params":         inst.Params,
+			"state":          st.State,
+			"state_since":    st.StateSince,
+			"state_reason":   st.StateReason,
+			"stopped_by":     st.StoppedBy,
+			"running":        st.Running,
+			"pid":            st.PID,
+			"run_id":         st.RunID,
+			"adopted":        st.Adopted,
+			"health":         st.Health,
+			"restarts":       st.Restarts,
+			"queue_position": st.QueuePosition,
+			"log_path":       s.LogPath(name),
+			"log_segments":   logSegmentSummaries(st.LogSegments),
+		})
+	}
+	return out
+}
+
+func logSegmentSummaries(segs []manager.LogSegment) []map[string]any {
+	out := make([]map[string]any, 0, len(segs))
+	for _, seg := range segs {
+		out = append(out, map[string]any{
+			"path":       seg.Path,
+			"size":       seg.Size,
+			"mod_time":   seg.ModTime,
+			"compressed": seg.Compressed,
 		})
 	}
 	return out
@@ -88,44 +179,84 @@ func (s *Service) LogPath(name string) string {
 	return filepath.Join(s.LogDir, fmt.Sprintf("%s.log", name))
 }
 
-func (s *Service) EnsureDirs(name string) error {
+// EnsureDirs creates the log and instance directories. With a run_as user the
+// instance directory is handed over to that user: a new one is simply
+// chowned, an existing one (and everything in it) only when its top
+// directory is owned by someone else, since walking a large world directory
+// on every start is costly.
+func (s *Service) EnsureDirs(name string, owner manager.RunAs) error {
 	if err := os.MkdirAll(s.LogDir, 0755); err != nil {
 		return err
 	}
-	if err := os.MkdirAll(s.InstanceDir(name), 0755); err != nil {
+	dir := s.InstanceDir(name)
+	fi, err := os.Stat(dir)
+	existed := err == nil
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	return nil
+	if owner.User == "" {
+		return nil
+	}
+	if !existed {
+		if err := os.Lchown(dir, int(owner.UID), int(owner.GID)); err != nil {
+			return fmt.Errorf("chown %s to %s: %w", dir, owner.User, err)
+		}
+		return nil
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Uid == owner.UID && st.Gid == owner.GID {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(path, int(owner.UID), int(owner.GID)); err != nil {
+			return fmt.Errorf("chown %s to %s: %w", path, owner.User, err)
+		}
+		return nil
+	})
 }
 
 // CreateInstance adds an instance to memory and persists it to instances.yaml.
-func (s *Service) CreateInstance(name string, templateName string, enabled bool, params map[string]string) error {
+func (s *Service) CreateInstance(name string, inst config.Instance) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if name == "" {
 		return fmt.Errorf("instance name is required")
 	}
-	if templateName == "" {
+	if inst.Template == "" {
 		return fmt.Errorf("template name is required")
 	}
 
-	if _, ok := s.Templates[templateName]; !ok {
-		return fmt.Errorf("unknown template: %s", templateName)
+	tpl, ok := s.Templates[inst.Template]
+	if !ok {
+		return fmt.Errorf("unknown template: %s", inst.Template)
 	}
 
 	if _, exists := s.Instances[name]; exists {
 		return fmt.Errorf("instance already exists: %s", name)
 	}
 
-	if params == nil {
-		params = map[string]string{}
+	if inst.Params == nil {
+		inst.Params = map[string]string{}
+	}
+	if _, err := config.ApplyParams(inst.Template, tpl.Params, inst.Params); err != nil {
+		return err
 	}
 
-	s.Instances[name] = config.Instance{
-		Template: templateName,
-		Enabled:  enabled,
-		Params:   params,
+	entries, err := config.ConvertSchedules(name, inst.Schedules)
+	if err != nil {
+		return err
+	}
+	if _, err := config.AutostartDelay(name, inst.Autostart); err != nil {
+		return err
+	}
+
+	s.Instances[name] = inst
+	if _, err := config.DependencyOrder(s.Instances, []string{name}); err != nil {
+		delete(s.Instances, name)
+		return err
 	}
 
 	if s.Store != nil {
@@ -136,8 +267,13 @@ func (s *Service) CreateInstance(name string, templateName string, enabled bool,
 		}
 	}
 
-	// create directories (best effort)
-	_ = s.EnsureDirs(name)
+	// create directories (best effort; resolving at start retries the chown)
+	owner, _ := config.ConvertRunAs(name, s.Templates[inst.Template].RunAs)
+	_ = s.EnsureDirs(name, owner)
+
+	if s.Scheduler != nil {
+		s.Scheduler.Set(name, entries)
+	}
 
 	return nil
 }
@@ -150,13 +286,21 @@ func (s *Service) DeleteInstance(name string, force bool, deleteData bool) error
 	if _, ok := s.Instances[name]; !ok {
 		return fmt.Errorf("unknown instance: %s", name)
 	}
+	if dependents := config.Dependents(s.Instances, name); len(dependents) > 0 {
+		return fmt.Errorf("instance %q is a dependency of %s", name, strings.Join(dependents, ", "))
+	}
 
 	st := s.Mgr.Status(name)
 	if st.Running {
 		if !force {
 			return fmt.Errorf("instance %q is running; use force to stop it before delete", name)
 		}
-		_, _ = s.Mgr.Stop(name)
+		_, _ = s.Mgr.Stop(name, "delete")
+	}
+	_ = s.Mgr.Remove(name)
+	s.Mgr.RemoveHistory(name)
+	if s.Scheduler != nil {
+		s.Scheduler.Remove(name)
 	}
 
 	delete(s.Instances, name)
@@ -186,21 +330,83 @@ func (s *Service) ResolveConfig(instanceName string) (manager.ServerConfig, stri
 		return manager.ServerConfig{}, "", fmt.Errorf("instance %q is disabled", instanceName)
 	}
 
+	return s.resolve(instanceName, inst)
+}
+
+// AdoptRunning re-attaches the manager to instance processes that survived an
+// agent restart. Disabled instances are adopted too: they are already running.
+// It returns the names of the adopted instances.
+func (s *Service) AdoptRunning() ([]string, error) {
+	s.mu.Lock()
+	insts := make(map[string]config.Instance, len(s.Instances))
+	for name, inst := range s.Instances {
+		insts[name] = inst
+	}
+	s.mu.Unlock()
+
+	var adopted []string
+	var errs []error
+	for name, inst := range insts {
+		cfg, logPath, resolveErr := s.resolve(name, inst)
+		if resolveErr != nil {
+			// A config that stopped validating must not hide a running process
+			// (it could then be started twice): adopt it with enough to stop it
+			errs = append(errs, fmt.Errorf("resolve %q: %w", name, resolveErr))
+			cfg, logPath = s.stopOnlyConfig(name, inst)
+		}
+		_, ok, err := s.Mgr.Adopt(cfg, logPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("adopt %q: %w", name, err))
+			continue
+		}
+		if ok {
+			adopted = append(adopted, name)
+			if cfg.Pty.Enabled {
+				log.Printf("[agent] %s ran on a pty that closed with the previous agent; it survived the hangup, but its output is no longer logged and console commands fail", name)
+			}
+			if resolveErr != nil {
+				log.Printf("[agent] %s re-adopted without its template settings (health, restart policy, log rotation) until its config is fixed; it can still be stopped", name)
+			}
+		}
+	}
+
+	return adopted, errors.Join(errs...)
+}
+
+// stopOnlyConfig is the config an instance whose config doesn't resolve is
+// adopted with: the template's stop chain if that still converts (stdin
+// steps become SIGTERM for adopted processes), the default one otherwise.
+func (s *Service) stopOnlyConfig(instanceName string, inst config.Instance) (manager.ServerConfig, string) {
+	cfg := manager.ServerConfig{Name: instanceName}
+	if tpl, ok := s.Templates[inst.Template]; ok {
+		if stopCfg, err := config.ConvertStopPublic(instanceName, tpl.Stop); err == nil {
+			cfg.Stop = stopCfg
+		}
+	}
+	return cfg, s.LogPath(instanceName)
+}
+
+func (s *Service) resolve(instanceName string, inst config.Instance) (manager.ServerConfig, string, error) {
 	tpl, ok := s.Templates[inst.Template]
 	if !ok {
 		return manager.ServerConfig{}, "", fmt.Errorf("instance %q references unknown template %q", instanceName, inst.Template)
 	}
 
-	if err := s.EnsureDirs(instanceName); err != nil {
+	runAs, err := config.ConvertRunAs(instanceName, tpl.RunAs)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
+	if err := s.EnsureDirs(instanceName, runAs); err != nil {
 		return manager.ServerConfig{}, "", fmt.Errorf("ensure dirs: %w", err)
 	}
 
 	instanceDir := s.InstanceDir(instanceName)
 	logPath := s.LogPath(instanceName)
 
-	ctx := map[string]string{}
-	for k, v := range inst.Params {
-		ctx[k] = v
+	ctx, err := config.ApplyParams(inst.Template, tpl.Params, inst.Params)
+	if err != nil {
+		return manager.ServerConfig{}, "", fmt.Errorf("instance %q: %w", instanceName, err)
 	}
 	ctx["instance_name"] = instanceName
 	ctx["instance_dir"] = instanceDir
@@ -239,6 +445,70 @@ func (s *Service) ResolveConfig(instanceName string) (manager.ServerConfig, stri
 		return manager.ServerConfig{}, "", err
 	}
 
+	restartCfg, err := config.ConvertRestart(instanceName, tpl.Restart)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
+	logsCfg, err := config.ConvertLogs(instanceName, tpl.Logs)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
+	resources, err := config.ConvertResources(instanceName, tpl.Resources)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
+	health := tpl.Health
+	for _, f := range []*string{&health.Address, &health.URL, &health.Command} {
+		if *f, err = render(*f, ctx); err != nil {
+			return manager.ServerConfig{}, "", fmt.Errorf("render template.health: %w", err)
+		}
+	}
+	healthCfg, err := config.ConvertHealth(instanceName, health)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
+	hooks := tpl.Hooks
+	for _, list := range []*[]config.Hook{&hooks.PreStart, &hooks.PostStart, &hooks.PreStop, &hooks.PostStop, &hooks.OnCrash} {
+		rendered := make([]config.Hook, len(*list))
+		for i, h := range *list {
+			rendered[i] = h
+			if rendered[i].Command, err = render(h.Command, ctx); err != nil {
+				return manager.ServerConfig{}, "", fmt.Errorf("render template.hooks: %w", err)
+			}
+		}
+		*list = rendered
+	}
+	hooksCfg, err := config.ConvertHooks(instanceName, hooks)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+	hooksCfg.Dir = instanceDir
+
+	sandbox := tpl.Sandbox
+	sandbox.Writable = make([]string, 0, len(tpl.Sandbox.Writable))
+	for _, w := range tpl.Sandbox.Writable {
+		r, err := render(w, ctx)
+		if err != nil {
+			return manager.ServerConfig{}, "", fmt.Errorf("render template.sandbox: %w", err)
+		}
+		sandbox.Writable = append(sandbox.Writable, r)
+	}
+	if sandbox.Hostname, err = render(sandbox.Hostname, ctx); err != nil {
+		return manager.ServerConfig{}, "", fmt.Errorf("render template.sandbox: %w", err)
+	}
+	sandboxCfg, err := config.ConvertSandbox(instanceName, sandbox, instanceDir)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+	ptyCfg, err := config.ConvertPty(instanceName, tpl.Pty)
+	if err != nil {
+		return manager.ServerConfig{}, "", err
+	}
+
 	cfg := manager.ServerConfig{
 		Name:    instanceName,
 		Command: command,
@@ -246,6 +516,15 @@ func (s *Service) ResolveConfig(instanceName string) (manager.ServerConfig, stri
 		Cwd:     cwd,
 		Env:     env,
 		Stop:    stopCfg,
+		Restart: restartCfg,
+		Logs:    logsCfg,
+
+		Resources: resources,
+		Health:    healthCfg,
+		Hooks:     hooksCfg,
+		RunAs:     runAs,
+		Sandbox:   sandboxCfg,
+		Pty:       ptyCfg,
 	}
 
 	return cfg, logPath, nil
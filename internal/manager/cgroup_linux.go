@@ -0,0 +1,158 @@
+//go:build linux
+
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cpuMaxPeriod is the cpu.max period in microseconds.
+const cpuMaxPeriod = 100000
+
+// attachCgroup creates the server's cgroup under the configured parent, writes
+// its limits and arranges for cmd to be cloned straight into it (so no child
+// can fork before being limited). The returned release func must be called
+// once cmd has started (or failed to).
+func (m *Manager) attachCgroup(cmd *exec.Cmd, name string, r Resources) (string, func(), error) {
+	if m.cgroupParent == "" {
+		return "", nil, fmt.Errorf("%s: template sets resources but cgroup_parent is not configured for the agent", name)
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", nil, fmt.Errorf("%s: resources require cgroup v2 mounted at %s", name, cgroupRoot)
+	}
+
+	parent := m.cgroupParent
+	if !filepath.IsAbs(parent) {
+		parent = filepath.Join(cgroupRoot, parent)
+	}
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", nil, notDelegated(parent, err)
+	}
+	if err := enableControllers(parent, r.controllers()); err != nil {
+		return "", nil, notDelegated(parent, err)
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return "", nil, notDelegated(parent, err)
+	}
+	if err := writeLimits(path, r); err != nil {
+		_ = os.Remove(path)
+		return "", nil, fmt.Errorf("%s: apply resources: %w", name, err)
+	}
+
+	fd, err := syscall.Open(path, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		_ = os.Remove(path)
+		return "", nil, notDelegated(parent, err)
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+
+	return path, func() { _ = syscall.Close(fd) }, nil
+}
+
+// removeCgroup deletes a server cgroup once its processes are gone (best effort).
+func removeCgroup(path string) {
+	if path != "" {
+		_ = os.Remove(path)
+	}
+}
+
+// cgroupOOMKills reads the oom_kill counter of a server cgroup.
+func cgroupOOMKills(path string) (int64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	n, err := readCounter(filepath.Join(path, "memory.events"), "oom_kill")
+	return n, err == nil
+}
+
+func notDelegated(parent string, err error) error {
+	return fmt.Errorf("cgroup v2 parent %s is not delegated to the agent (create it and hand it to the agent user, e.g. systemd Delegate=yes): %w", parent, err)
+}
+
+// enableControllers makes the controllers available to children of parent.
+func enableControllers(parent string, controllers []string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	available := strings.Fields(string(b))
+
+	b, err = os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(b))
+
+	var missing []string
+	for _, c := range controllers {
+		if !contains(available, c) {
+			return fmt.Errorf("controller %q is not available in %s", c, parent)
+		}
+		if !contains(enabled, c) {
+			missing = append(missing, "+"+c)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(missing, " ")), 0644); err != nil {
+		return fmt.Errorf("enable controllers %v: %w", missing, err)
+	}
+	return nil
+}
+
+func writeLimits(path string, r Resources) error {
+	files := map[string]string{}
+	if r.MemoryMax > 0 {
+		files["memory.max"] = strconv.FormatInt(r.MemoryMax, 10)
+	}
+	if r.MemoryHigh > 0 {
+		files["memory.high"] = strconv.FormatInt(r.MemoryHigh, 10)
+	}
+	if r.CPUWeight > 0 {
+		files["cpu.weight"] = strconv.Itoa(r.CPUWeight)
+	}
+	if r.CPUQuotaPercent > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", r.CPUQuotaPercent*cpuMaxPeriod/100, cpuMaxPeriod)
+	}
+	if r.PidsMax > 0 {
+		files["pids.max"] = strconv.Itoa(r.PidsMax)
+	}
+	if r.IOWeight > 0 {
+		files["io.weight"] = fmt.Sprintf("default %d", r.IOWeight)
+	}
+
+	for file, v := range files {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(v), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
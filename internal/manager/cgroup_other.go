@@ -0,0 +1,16 @@
+//go:build !linux
+
+package manager
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func (m *Manager) attachCgroup(cmd *exec.Cmd, name string, r Resources) (string, func(), error) {
+	return "", nil, fmt.Errorf("%s: resource limits require Linux cgroup v2", name)
+}
+
+func removeCgroup(path string) {}
+
+func cgroupOOMKills(path string) (int64, bool) { return 0, false }
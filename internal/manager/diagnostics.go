@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// exitLogTail is how many log lines are kept when a server ends abnormally
+// (in its state and its run history).
+const exitLogTail = 30
+
+// oomBaseline holds the OOM kill counters at spawn, so an exit can tell
+// whether the OOM killer struck during this run. -1 means unknown.
+type oomBaseline struct {
+	cgroup int64
+	system int64
+}
+
+// readOOMBaseline samples the counters for a run starting in cgroup (which
+// may be empty).
+func readOOMBaseline(cgroup string) oomBaseline {
+	b := oomBaseline{cgroup: -1, system: -1}
+	if n, ok := cgroupOOMKills(cgroup); ok {
+		b.cgroup = n
+	}
+	if n, err := readSystemOOMKills(); err == nil {
+		b.system = n
+	}
+	return b
+}
+
+// recordExitDiagnostics works out how p's process ended: the terminating
+// signal, whether it dumped core, and whether the kernel OOM killer was
+// involved. Caller must hold m.mu, and call it before the run's cgroup is
+// removed.
+func (m *Manager) recordExitDiagnostics(p *managedProc, waitErr error) {
+	st := &p.state
+	st.ExitSignal = ""
+	st.CoreDumped = false
+	st.OOMKilled = false
+
+	var sig syscall.Signal
+	if ee := new(exec.ExitError); errors.As(waitErr, &ee) {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			sig = ws.Signal()
+			st.CoreDumped = ws.CoreDump()
+		}
+	}
+	if sig == 0 && p.cfg.Sandbox.Enabled && st.ExitCode > 128 && st.ExitCode <= 128+64 {
+		// The sandbox init exits with 128+n when its child was killed by signal n
+		sig = syscall.Signal(st.ExitCode - 128)
+	}
+	if sig != 0 {
+		st.ExitSignal = signalName(sig)
+	}
+
+	// With a cgroup the kernel tells us; without one, a SIGKILL nobody here
+	// sent while the system-wide OOM kill counter went up is the OOM killer
+	// in all likelihood.
+	if n, ok := cgroupOOMKills(st.Cgroup); ok && p.oomBaseline.cgroup >= 0 {
+		st.OOMKilled = n > p.oomBaseline.cgroup
+	} else if sig == syscall.SIGKILL && !p.killed && p.oomBaseline.system >= 0 {
+		if n, err := readSystemOOMKills(); err == nil {
+			st.OOMKilled = n > p.oomBaseline.system
+		}
+	}
+}
+
+// exitDescription says why a process ended, e.g. "killed by SIGSEGV (core
+// dumped)", falling back to the wait error.
+func exitDescription(st ServerState, waitErr error) string {
+	switch {
+	case st.OOMKilled:
+		return "killed by the kernel OOM killer"
+	case st.ExitSignal != "" && st.CoreDumped:
+		return "killed by " + st.ExitSignal + " (core dumped)"
+	case st.ExitSignal != "":
+		return "killed by " + st.ExitSignal
+	}
+	if ee := new(exec.ExitError); errors.As(waitErr, &ee) {
+		return fmt.Sprintf("exit code %d", st.ExitCode)
+	}
+	if waitErr != nil {
+		return waitErr.Error()
+	}
+	return fmt.Sprintf("exit code %d", st.ExitCode)
+}
+
+// readSystemOOMKills returns the kernel's count of OOM kills since boot
+// (Linux 4.13+).
+func readSystemOOMKills() (int64, error) {
+	return readCounter("/proc/vmstat", "oom_kill")
+}
+
+// readCounter finds a "key value" line in a flat keyed file such as
+// /proc/vmstat or a cgroup's memory.events.
+func readCounter(path string, key string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && f[0] == key {
+			return strconv.ParseInt(f[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%s: no %s", path, key)
+}
@@ -0,0 +1,220 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type HealthCheckType string
+
+const (
+	HealthTCP  HealthCheckType = "tcp"  // connect to Address
+	HealthHTTP HealthCheckType = "http" // GET URL, expect 2xx/3xx
+	HealthExec HealthCheckType = "exec" // run Command via sh -c, expect exit 0
+	HealthLog  HealthCheckType = "log"  // Pattern matches a line logged since start (readiness only)
+)
+
+type HealthCheck struct {
+	Type     HealthCheckType // empty disables probing
+	Address  string
+	URL      string
+	Command  string
+	Pattern  *regexp.Regexp
+	Interval time.Duration
+	Timeout  time.Duration
+
+	SuccessThreshold int // consecutive successes to become ready
+	FailureThreshold int // consecutive failures (once ready) to become unhealthy
+	RestartAfter     int // consecutive failures (once ready) that restart the server (0 = never)
+}
+
+type HealthStatus string
+
+const (
+	HealthStarting  HealthStatus = "starting"
+	HealthReady     HealthStatus = "ready"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// NetworkProbe runs a tcp or http health check. The manager stays free of
+// network code, so the agent supplies it (see SetNetworkProbe).
+type NetworkProbe func(ctx context.Context, hc HealthCheck) error
+
+// SetNetworkProbe sets the function tcp and http health checks run with.
+// Without one those checks always fail.
+func (m *Manager) SetNetworkProbe(probe NetworkProbe) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.netProbe = probe
+}
+
+// healthState is the probe bookkeeping of one run.
+type healthState struct {
+	successes int
+	failures  int
+	logOffset int64
+}
+
+// probeHealth runs p's health check every interval until done is closed.
+func (m *Manager) probeHealth(p *managedProc, done <-chan struct{}) {
+	hc := p.cfg.Health
+
+	m.mu.Lock()
+	logPath := p.logPath
+	cwd := p.cfg.Cwd
+	env := p.cfg.Env
+	runAs := p.cfg.RunAs
+	netProbe := m.netProbe
+	m.mu.Unlock()
+
+	hs := &healthState{}
+	if hc.Type == HealthLog {
+		// Only lines logged by this run count
+		hs.logOffset, _ = LogSize(logPath)
+	}
+
+	t := time.NewTicker(hc.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		err := runProbe(hc, hs, netProbe, logPath, cwd, env, runAs)
+
+		m.mu.Lock()
+		if !p.state.Running || p.done != done {
+			m.mu.Unlock()
+			return
+		}
+		// While cordoned an unhealthy server is left running (and probed), as
+		// it could not be started again
+		restart := m.recordProbe(p, hs, err) && !m.cordoned
+		m.mu.Unlock()
+
+		if restart {
+			go m.restartUnhealthy(p, done)
+			return
+		}
+	}
+}
+
+// recordProbe updates p's health from one probe result and reports whether
+// the server should be restarted. Caller must hold m.mu.
+func (m *Manager) recordProbe(p *managedProc, hs *healthState, probeErr error) bool {
+	hc := p.cfg.Health
+	p.state.HealthCheckedAt = time.Now()
+
+	if probeErr == nil {
+		hs.successes++
+		hs.failures = 0
+		p.state.HealthMessage = ""
+		if hs.successes >= hc.SuccessThreshold {
+			p.state.Health = HealthReady
+			if p.state.State == StateStarting {
+				_ = p.transition(StateRunning, "health check passed")
+				m.kickStartQueue()
+			}
+		}
+		return false
+	}
+
+	hs.successes = 0
+	p.state.HealthMessage = probeErr.Error()
+
+	// Failures while starting are expected; they only count once ready
+	if p.state.Health == HealthStarting || hc.Type == HealthLog {
+		return false
+	}
+
+	hs.failures++
+	if hs.failures >= hc.FailureThreshold {
+		p.state.Health = HealthUnhealthy
+	}
+	return hc.RestartAfter > 0 && hs.failures >= hc.RestartAfter
+}
+
+// restartUnhealthy stops p (using its stop config) and starts it again.
+func (m *Manager) restartUnhealthy(p *managedProc, run <-chan struct{}) {
+	name := p.cfg.Name
+	if _, err := m.Stop(name, TriggerHealthCheck); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.procs[name] != p || p.state.Running || p.done != run {
+		// someone else started or replaced it meanwhile
+		return
+	}
+	if m.cordoned {
+		p.state.StateReason += "; not started again: the agent was cordoned meanwhile"
+		return
+	}
+	if err := m.launch(p, fmt.Sprintf("restarted after %d failed health checks", p.cfg.Health.RestartAfter), TriggerHealthCheck); err != nil {
+		return
+	}
+	p.state.Restarts++
+}
+
+func runProbe(hc HealthCheck, hs *healthState, netProbe NetworkProbe, logPath, cwd string, env []string, runAs RunAs) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout)
+	defer cancel()
+
+	switch hc.Type {
+	case HealthTCP, HealthHTTP:
+		if netProbe == nil {
+			return fmt.Errorf("no %s probe available", hc.Type)
+		}
+		return netProbe(ctx, hc)
+
+	case HealthExec:
+		cmd := exec.CommandContext(ctx, "sh", "-c", runAs.shell(hc.Command))
+		cmd.Dir = cwd
+		cmd.Env = append(os.Environ(), env...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		runAs.apply(cmd)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			msg := strings.TrimSpace(string(out))
+			if msg != "" {
+				return fmt.Errorf("%w: %s", err, msg)
+			}
+			return err
+		}
+		return nil
+
+	case HealthLog:
+		size, err := LogSize(logPath)
+		if err != nil {
+			return err
+		}
+		lines, err := ReadLogSince(logPath, hs.logOffset, 1<<20)
+		if err != nil {
+			return err
+		}
+		hs.logOffset = size
+		for _, l := range lines {
+			if hc.Pattern.MatchString(l) {
+				return nil
+			}
+		}
+		if hs.successes > 0 {
+			// already matched once; a log pattern never un-readies
+			return nil
+		}
+		return fmt.Errorf("waiting for log line matching %q", hc.Pattern.String())
+
+	default:
+		return fmt.Errorf("unknown health check type %q", hc.Type)
+	}
+}
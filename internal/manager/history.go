@@ -0,0 +1,350 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// historyMaxRuns is how many runs are kept per server
+	historyMaxRuns = 200
+
+	// historyTrimAt is how many runs a history file may grow to before it
+	// is rewritten with the last historyMaxRuns, so most runs are a single
+	// append
+	historyTrimAt = 2 * historyMaxRuns
+)
+
+// Triggers of starts and stops, recorded in run history. Callers may use
+// their own (e.g. "schedule:<name>").
+const (
+	TriggerAPI           = "api"
+	TriggerRestartPolicy = "restart-policy"
+	TriggerHealthCheck   = "health-check"
+	TriggerAdopted       = "adopted"
+)
+
+// RunHistory describes one finished run of a server (or a start that failed
+// before the process existed). It is persisted as one JSON line per run.
+type RunHistory struct {
+	RunID     string    `json:"run_id"`
+	Trigger   string    `json:"trigger"` // what started the run
+	PID       int       `json:"pid,omitempty"`
+	Adopted   bool      `json:"adopted,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Duration  string    `json:"duration"` // e.g. "2h13m5.2s"
+
+	State    LifecycleState `json:"state"` // stopped, killed, exited, crashed or failed
+	Reason   string         `json:"reason"`
+	ExitCode int            `json:"exit_code"`
+	Signal   string         `json:"signal,omitempty"` // signal that terminated the process
+
+	CoreDumped bool `json:"core_dumped,omitempty"`
+	OOMKilled  bool `json:"oom_killed,omitempty"`
+
+	StopTrigger string `json:"stop_trigger,omitempty"` // what requested the stop
+	StoppedBy   string `json:"stopped_by,omitempty"`   // stop step that ended the process
+
+	LogTail []string `json:"log_tail,omitempty"` // last log lines of abnormal ends
+}
+
+func newRunID(start time.Time, pid int) string {
+	return fmt.Sprintf("%s-%d", start.UTC().Format("20060102T150405"), pid)
+}
+
+// pendingRun is a run record waiting to be written. For abnormal ends of p
+// the log tail is read before writing and kept in p's state too, unless
+// another run ended meanwhile.
+type pendingRun struct {
+	name string
+	run  RunHistory
+	p    *managedProc
+	seq  int // p.runsEnded when the run was recorded
+}
+
+// recordRun queues the run that just ended for p's history. The log tail of
+// an abnormal end is read and kept in p's state shortly after. Caller must
+// hold m.mu.
+func (m *Manager) recordRun(p *managedProc) {
+	st := p.state
+	h := RunHistory{
+		RunID:     st.RunID,
+		Trigger:   p.trigger,
+		PID:       st.PID,
+		Adopted:   st.Adopted,
+		StartedAt: st.StartedAt,
+		EndedAt:   time.Now(),
+		State:     st.State,
+		Reason:    st.StateReason,
+		ExitCode:  st.ExitCode,
+		StoppedBy: st.StoppedBy,
+
+		Signal:     st.ExitSignal,
+		CoreDumped: st.CoreDumped,
+		OOMKilled:  st.OOMKilled,
+	}
+	if h.RunID == "" {
+		// failed before a process existed
+		h.StartedAt = h.EndedAt
+		h.RunID = newRunID(h.StartedAt, 0)
+	}
+	h.Duration = h.EndedAt.Sub(h.StartedAt).Round(time.Millisecond).String()
+
+	if st.State == StateStopped || st.State == StateKilled {
+		h.StopTrigger = p.stopTrigger
+	}
+
+	p.runsEnded++
+	pr := pendingRun{name: p.cfg.Name, run: h, seq: p.runsEnded}
+	if st.State == StateCrashed || st.State == StateKilled || st.State == StateFailed {
+		pr.p = p
+	}
+	m.queueHistory(pr)
+}
+
+// recordLostRun records a run that ended while the agent was down; only its
+// start is known. Caller must hold m.mu.
+func (m *Manager) recordLostRun(rec runRecord) {
+	h := RunHistory{
+		RunID:     rec.RunID,
+		Trigger:   rec.Trigger,
+		PID:       rec.PID,
+		StartedAt: rec.StartedAt,
+		EndedAt:   time.Now(),
+		State:     StateExited,
+		Reason:    "process ended while the agent was down (end time and exit status unknown)",
+		ExitCode:  -1,
+	}
+	if rec.Pty {
+		h.Reason = "process ended while the agent was down (it ran on a pty, which hangs up when the agent exits)"
+	}
+	if h.RunID == "" {
+		h.RunID = newRunID(rec.StartedAt, rec.PID)
+	}
+	h.Duration = "unknown"
+	m.queueHistory(pendingRun{name: rec.Name, run: h})
+}
+
+// queueHistory queues a run record and makes sure a writer is running.
+// Caller must hold m.mu.
+func (m *Manager) queueHistory(pr pendingRun) {
+	if m.historyDir == "" && pr.p == nil {
+		return
+	}
+	m.historyPending = append(m.historyPending, pr)
+	if !m.historyWriting {
+		m.historyWriting = true
+		go m.writeHistory()
+	}
+}
+
+// writeHistory writes queued run records in order until none are left.
+func (m *Manager) writeHistory() {
+	for {
+		m.mu.Lock()
+		batch := m.historyPending
+		m.historyPending = nil
+		if len(batch) == 0 {
+			m.historyWriting = false
+			m.mu.Unlock()
+			return
+		}
+		// Taken before mu is released, so RemoveHistory cannot slip in
+		// between dropping the pending records and these writes
+		m.historyMu.Lock()
+		m.mu.Unlock()
+
+		errs := make([]error, len(batch))
+		for i := range batch {
+			pr := &batch[i]
+			if pr.p != nil {
+				pr.run.LogTail, _, _ = TailLog(pr.p.logPath, exitLogTail)
+			}
+			errs[i] = m.appendHistory(pr.name, pr.run)
+		}
+		m.historyMu.Unlock()
+
+		m.mu.Lock()
+		for i, pr := range batch {
+			if pr.p == nil || pr.p.runsEnded != pr.seq || pr.p.state.State != pr.run.State {
+				continue
+			}
+			pr.p.state.ExitLogTail = pr.run.LogTail
+			if errs[i] != nil {
+				pr.p.state.LastError = fmt.Sprintf("record run history: %v", errs[i])
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) historyPath(name string) string {
+	return filepath.Join(m.historyDir, name+".jsonl")
+}
+
+// appendHistory adds one run to name's history file, trimming the file once
+// it holds historyTrimAt runs. Caller must hold m.historyMu.
+func (m *Manager) appendHistory(name string, h RunHistory) error {
+	if m.historyDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.historyDir, 0755); err != nil {
+		return fmt.Errorf("mkdir history dir: %w", err)
+	}
+
+	n, known := m.historyRuns[name]
+	if !known {
+		runs, err := m.readHistory(name)
+		if err != nil {
+			return err
+		}
+		n = len(runs)
+	}
+
+	line, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("encode history: %w", err)
+	}
+	f, err := os.OpenFile(m.historyPath(name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		delete(m.historyRuns, name) // recount next time
+		return fmt.Errorf("append history: %w", err)
+	}
+	n++
+
+	if n >= historyTrimAt {
+		if err := m.trimHistory(name); err != nil {
+			delete(m.historyRuns, name)
+			return err
+		}
+		n = historyMaxRuns
+	}
+	m.historyRuns[name] = n
+	return nil
+}
+
+// trimHistory rewrites name's history file with only its last
+// historyMaxRuns runs. Caller must hold m.historyMu.
+func (m *Manager) trimHistory(name string) error {
+	runs, err := m.readHistory(name)
+	if err != nil {
+		return err
+	}
+	if len(runs) > historyMaxRuns {
+		runs = runs[len(runs)-historyMaxRuns:]
+	}
+
+	// Atomic write: write temp then rename
+	path := m.historyPath(name)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("write temp history: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range runs {
+		if err := enc.Encode(r); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("encode history: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write temp history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write temp history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename temp -> history: %w", err)
+	}
+	return nil
+}
+
+// readHistory returns the persisted runs of name, oldest first. Lines that
+// don't parse (e.g. a torn write) are skipped. Caller must hold m.historyMu.
+func (m *Manager) readHistory(name string) ([]RunHistory, error) {
+	if m.historyDir == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(m.historyPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	defer f.Close()
+
+	var runs []RunHistory
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var r RunHistory
+		if err := json.Unmarshal(sc.Bytes(), &r); err == nil {
+			runs = append(runs, r)
+		}
+	}
+	return runs, sc.Err()
+}
+
+// History returns up to limit (0 = all) past runs of a server, newest first.
+// Runs that ended moments ago may not be written yet.
+func (m *Manager) History(name string, limit int) ([]RunHistory, error) {
+	m.historyMu.Lock()
+	runs, err := m.readHistory(name)
+	m.historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > historyMaxRuns {
+		runs = runs[len(runs)-historyMaxRuns:]
+	}
+
+	out := make([]RunHistory, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, runs[i])
+	}
+	return out, nil
+}
+
+// RemoveHistory deletes a server's run history.
+func (m *Manager) RemoveHistory(name string) {
+	if m.historyDir == "" {
+		return
+	}
+
+	// Drop runs not written yet; a batch already being written holds
+	// historyMu until it is on disk
+	m.mu.Lock()
+	pending := m.historyPending[:0]
+	for _, pr := range m.historyPending {
+		if pr.name != name {
+			pending = append(pending, pr)
+		}
+	}
+	m.historyPending = pending
+	m.mu.Unlock()
+
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+	_ = os.Remove(m.historyPath(name))
+	delete(m.historyRuns, name)
+}
@@ -0,0 +1,141 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestAppendHistoryTrims(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager("", dir, "")
+
+	tests := []struct {
+		appends   int // runs appended so far
+		wantLines int // runs in the file after them
+	}{
+		{1, 1},
+		{historyMaxRuns, historyMaxRuns},
+		{historyTrimAt - 1, historyTrimAt - 1},
+		{historyTrimAt, historyMaxRuns},
+		{historyTrimAt + 1, historyMaxRuns + 1},
+	}
+	n := 0
+	for _, tt := range tests {
+		m.historyMu.Lock()
+		for ; n < tt.appends; n++ {
+			if err := m.appendHistory("a", RunHistory{RunID: fmt.Sprint(n)}); err != nil {
+				t.Fatalf("appendHistory: %v", err)
+			}
+		}
+		m.historyMu.Unlock()
+		if got := countLines(t, filepath.Join(dir, "a.jsonl")); got != tt.wantLines {
+			t.Errorf("after %d runs the file holds %d, want %d", tt.appends, got, tt.wantLines)
+		}
+	}
+
+	runs, err := m.History("a", 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(runs) != historyMaxRuns {
+		t.Fatalf("History returned %d runs, want %d", len(runs), historyMaxRuns)
+	}
+	if first, last := runs[0].RunID, runs[len(runs)-1].RunID; first != fmt.Sprint(n-1) || last != fmt.Sprint(n-historyMaxRuns) {
+		t.Errorf("History runs %s..%s, want newest first %d..%d", first, last, n-1, n-historyMaxRuns)
+	}
+
+	runs, err = m.History("a", 3)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(runs) != 3 || runs[0].RunID != fmt.Sprint(n-1) {
+		t.Errorf("History with limit 3 = %d runs starting at %v", len(runs), runs)
+	}
+}
+
+// An existing file is counted once, so trimming also applies to history
+// written by an earlier agent.
+func TestAppendHistoryCountsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	old := NewManager("", dir, "")
+	old.historyMu.Lock()
+	for i := 0; i < historyTrimAt-1; i++ {
+		if err := old.appendHistory("a", RunHistory{RunID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("appendHistory: %v", err)
+		}
+	}
+	old.historyMu.Unlock()
+
+	m := NewManager("", dir, "")
+	m.historyMu.Lock()
+	err := m.appendHistory("a", RunHistory{RunID: "last"})
+	m.historyMu.Unlock()
+	if err != nil {
+		t.Fatalf("appendHistory: %v", err)
+	}
+	if got := countLines(t, filepath.Join(dir, "a.jsonl")); got != historyMaxRuns {
+		t.Errorf("file holds %d runs, want %d", got, historyMaxRuns)
+	}
+}
+
+func TestQueuedHistoryIsWrittenInOrder(t *testing.T) {
+	m := NewManager("", t.TempDir(), "")
+
+	m.mu.Lock()
+	for i := 0; i < 10; i++ {
+		m.queueHistory(pendingRun{name: "a", run: RunHistory{RunID: fmt.Sprint(i)}})
+	}
+	m.queueHistory(pendingRun{name: "b", run: RunHistory{RunID: "b"}})
+	m.mu.Unlock()
+	// Not written yet, so dropped
+	m.RemoveHistory("b")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		idle := !m.historyWriting
+		m.mu.Unlock()
+		if idle {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("history writer still running after 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runs, err := m.History("a", 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(runs) != 10 {
+		t.Fatalf("History returned %d runs, want 10", len(runs))
+	}
+	for i, r := range runs {
+		if want := fmt.Sprint(9 - i); r.RunID != want {
+			t.Errorf("run %d = %s, want %s", i, r.RunID, want)
+		}
+	}
+	if runs, _ := m.History("b", 0); len(runs) != 0 {
+		t.Errorf("removed history of b has %d runs", len(runs))
+	}
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const defaultHookTimeout = 60 * time.Second
+
+type HookEvent string
+
+const (
+	HookPreStart  HookEvent = "pre_start"  // before spawning; a failure aborts the start
+	HookPostStart HookEvent = "post_start" // after spawning, in the background
+	HookPreStop   HookEvent = "pre_stop"   // before the stop action
+	HookPostStop  HookEvent = "post_stop"  // after the process exited, for any reason
+	HookOnCrash   HookEvent = "on_crash"   // after the process exited unexpectedly
+)
+
+// Hook is a shell command run around the server lifecycle, with its output
+// appended to the server log.
+type Hook struct {
+	Command string
+	Timeout time.Duration
+}
+
+type Hooks struct {
+	Dir string // working directory for hooks; defaults to the server's Cwd
+
+	PreStart  []Hook
+	PostStart []Hook
+	PreStop   []Hook
+	PostStop  []Hook
+	OnCrash   []Hook
+}
+
+func (h Hooks) forEvent(ev HookEvent) []Hook {
+	switch ev {
+	case HookPreStart:
+		return h.PreStart
+	case HookPostStart:
+		return h.PostStart
+	case HookPreStop:
+		return h.PreStop
+	case HookPostStop:
+		return h.PostStop
+	case HookOnCrash:
+		return h.OnCrash
+	}
+	return nil
+}
+
+// runHooks runs the hooks for ev in order and stops at the first failure.
+// It must be called without m.mu held. exitCode is exported to post_stop and
+// on_crash hooks (nil otherwise).
+func (m *Manager) runHooks(p *managedProc, ev HookEvent, exitCode *int) error {
+	hooks := p.cfg.Hooks.forEvent(ev)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(), p.cfg.Env...)
+	env = append(env,
+		"RPM_INSTANCE="+p.cfg.Name,
+		"RPM_HOOK="+string(ev),
+		"RPM_LOG_PATH="+p.logPath,
+	)
+	if exitCode != nil {
+		env = append(env, "RPM_EXIT_CODE="+strconv.Itoa(*exitCode))
+	}
+
+	dir := p.cfg.Hooks.Dir
+	if dir == "" {
+		dir = p.cfg.Cwd
+	}
+
+	for i, h := range hooks {
+		if err := RunHook(h, p.cfg.RunAs, dir, env, p.logPath, fmt.Sprintf("%s #%d", ev, i+1)); err != nil {
+			return fmt.Errorf("%s hook #%d: %w", ev, i+1, err)
+		}
+	}
+	return nil
+}
+
+// runHooksAsync runs hooks in the background; failures only show up in the log.
+func (m *Manager) runHooksAsync(p *managedProc, exitCode *int, events ...HookEvent) {
+	go func() {
+		for _, ev := range events {
+			_ = m.runHooks(p, ev, exitCode)
+		}
+	}()
+}
+
+// RunHook runs a single hook as runAs in dir with its output appended to
+// logPath.
+func RunHook(h Hook, runAs RunAs, dir string, env []string, logPath string, label string) error {
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	defer logFile.Close()
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", runAs.shell(h.Command))
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Kill the whole hook process group on timeout, not just the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	runAs.apply(cmd)
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+
+	fmt.Fprintf(logFile, "[hook %s] $ %s\n", label, h.Command)
+	started := time.Now()
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		fmt.Fprintf(logFile, "[hook %s] failed: %v\n", label, err)
+		return err
+	}
+	fmt.Fprintf(logFile, "[hook %s] ok (%s)\n", label, time.Since(started).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,183 @@
+package manager
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const logRotateCheckInterval = 30 * time.Second
+
+// segmentSuffix matches what rotateLog appends to the live log's path.
+var segmentSuffix = regexp.MustCompile(`^\.\d{8}-\d{6}(\.gz)?$`)
+
+// LogRotation configures copy-truncate rotation of an instance log. The
+// process keeps its O_APPEND descriptor, so no restart is needed; lines
+// written between the copy and the truncate can be lost.
+type LogRotation struct {
+	MaxSize     int64         // rotate when the live log exceeds this many bytes (0 = no size trigger)
+	RotateEvery time.Duration // rotate when the live log is older than this (0 = no age trigger)
+	MaxFiles    int           // rotated segments to keep (0 = unlimited)
+	MaxAge      time.Duration // delete segments older than this (0 = keep forever)
+	Compress    bool          // gzip rotated segments
+}
+
+func (r LogRotation) enabled() bool {
+	return r.MaxSize > 0 || r.RotateEvery > 0
+}
+
+func (r LogRotation) retains() bool {
+	return r.MaxFiles > 0 || r.MaxAge > 0
+}
+
+type LogSegment struct {
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	Compressed bool
+}
+
+// rotateLogs periodically rotates p's log until done is closed.
+func (m *Manager) rotateLogs(logPath string, rot LogRotation, done <-chan struct{}) {
+	t := time.NewTicker(logRotateCheckInterval)
+	defer t.Stop()
+
+	lastRotation := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		fi, err := os.Stat(logPath)
+		if err != nil || fi.Size() == 0 {
+			continue
+		}
+
+		due := rot.MaxSize > 0 && fi.Size() >= rot.MaxSize
+		due = due || (rot.RotateEvery > 0 && time.Since(lastRotation) >= rot.RotateEvery)
+		if !due {
+			continue
+		}
+
+		// Errors are retried on the next tick; rotation must never disturb the process
+		if err := rotateLog(logPath, rot); err == nil {
+			lastRotation = time.Now()
+		}
+	}
+}
+
+// rotateLog copies the live log into a timestamped segment, truncates it in
+// place and applies retention to older segments.
+func rotateLog(logPath string, rot LogRotation) error {
+	segPath := fmt.Sprintf("%s.%s", logPath, time.Now().Format("20060102-150405"))
+	if rot.Compress {
+		segPath += ".gz"
+	}
+
+	src, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(segPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create log segment: %w", err)
+	}
+
+	var w io.Writer = dst
+	var zw *gzip.Writer
+	if rot.Compress {
+		zw = gzip.NewWriter(dst)
+		w = zw
+	}
+
+	_, err = io.Copy(w, src)
+	if zw != nil {
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(segPath)
+		return fmt.Errorf("copy log segment: %w", err)
+	}
+
+	if err := os.Truncate(logPath, 0); err != nil {
+		return fmt.Errorf("truncate log: %w", err)
+	}
+
+	return pruneLogSegments(logPath, rot)
+}
+
+// pruneLogsAsync applies retention to p's segments in the background.
+// Rotation only prunes while the process runs; this is called on start and
+// exit too, so segments of instances that are mostly stopped still expire.
+func pruneLogsAsync(p *managedProc) {
+	if p.cfg.Logs.retains() {
+		go func(logPath string, rot LogRotation) { _ = pruneLogSegments(logPath, rot) }(p.logPath, p.cfg.Logs)
+	}
+}
+
+func pruneLogSegments(logPath string, rot LogRotation) error {
+	segs, err := ListLogSegments(logPath)
+	if err != nil {
+		return err
+	}
+
+	// ListLogSegments returns newest first
+	for i, s := range segs {
+		tooMany := rot.MaxFiles > 0 && i >= rot.MaxFiles
+		tooOld := rot.MaxAge > 0 && time.Since(s.ModTime) > rot.MaxAge
+		if tooMany || tooOld {
+			_ = os.Remove(s.Path)
+		}
+	}
+	return nil
+}
+
+// ListLogSegments returns the rotated segments of logPath, newest first.
+// Only names rotateLog produces count, so the live log of an instance whose
+// name starts with this one's (e.g. "a.log" next to "a") is never a segment.
+func ListLogSegments(logPath string) ([]LogSegment, error) {
+	matches, err := filepath.Glob(globEscape(logPath) + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]LogSegment, 0, len(matches))
+	for _, path := range matches {
+		if !segmentSuffix.MatchString(strings.TrimPrefix(path, logPath)) {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		out = append(out, LogSegment{
+			Path:       path,
+			Size:       fi.Size(),
+			ModTime:    fi.ModTime(),
+			Compressed: strings.HasSuffix(path, ".gz"),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Path > out[j].Path })
+	return out, nil
+}
+
+func globEscape(s string) string {
+	r := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+	return r.Replace(s)
+}
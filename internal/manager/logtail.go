@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const logFollowPollInterval = 250 * time.Millisecond
+
+// TailLog returns the last n lines of the log at path and the offset the
+// read ended at, so a follower can continue from there without gaps. A
+// missing log yields no lines and offset 0.
+func TailLog(path string, n int) ([]string, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []string{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := fi.Size()
+	if n <= 0 || size == 0 {
+		return []string{}, size, nil
+	}
+
+	// Read backwards in chunks until we have n full lines (or hit the start)
+	const chunk = 16 * 1024
+	var buf []byte
+	pos := size
+	for pos > 0 && bytes.Count(buf, []byte{'\n'}) <= n {
+		step := int64(chunk)
+		if step > pos {
+			step = pos
+		}
+		pos -= step
+		b := make([]byte, step)
+		if _, err := f.ReadAt(b, pos); err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		buf = append(b, buf...)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(buf, []byte{'\n'}), []byte{'\n'})
+	if pos > 0 && len(lines) > 0 {
+		// first line is likely partial
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		out = append(out, string(l))
+	}
+	return out, size, nil
+}
+
+// ReadLogSince returns the complete lines appended to the log at path after
+// offset (at most maxBytes of them). If the log shrank in the meantime
+// (rotation) it is read from the start.
+func ReadLogSince(path string, offset int64, maxBytes int64) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < offset {
+		offset = 0
+	}
+
+	b, err := io.ReadAll(io.LimitReader(io.NewSectionReader(f, offset, fi.Size()-offset), maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{}
+	for _, l := range bytes.Split(bytes.TrimSuffix(b, []byte{'\n'}), []byte{'\n'}) {
+		if len(l) > 0 {
+			out = append(out, string(l))
+		}
+	}
+	return out, nil
+}
+
+// LogSize returns the current size of the log at path (0 if missing).
+func LogSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// FollowLog calls fn with data appended to the log at path after offset until
+// ctx is cancelled or fn returns an error. A log that shrinks (rotation
+// truncated it) is followed again from the start.
+func FollowLog(ctx context.Context, path string, offset int64, fn func([]byte) error) error {
+	t := time.NewTicker(logFollowPollInterval)
+	defer t.Stop()
+
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			offset = 0
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if fi.Size() < offset {
+			offset = 0
+		}
+
+		for offset < fi.Size() {
+			nr, err := f.ReadAt(buf, offset)
+			if nr > 0 {
+				offset += int64(nr)
+				if ferr := fn(buf[:nr]); ferr != nil {
+					_ = f.Close()
+					return ferr
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTailLog(t *testing.T) {
+	// Enough lines to span several of TailLog's 16 KiB reads
+	var long []string
+	for i := 0; i < 2000; i++ {
+		long = append(long, fmt.Sprintf("line %04d %s", i, strings.Repeat("x", 40)))
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    []string
+	}{
+		{"empty file", "", 10, []string{}},
+		{"zero lines", "a\nb\n", 0, []string{}},
+		{"trailing newline", "a\nb\nc\n", 2, []string{"b", "c"}},
+		{"no trailing newline", "a\nb\nc", 2, []string{"b", "c"}},
+		{"more lines than the file has", "a\nb\n", 10, []string{"a", "b"}},
+		{"more lines than the file has, no trailing newline", "a\nb", 10, []string{"a", "b"}},
+		{"exactly the file", "a\nb\n", 2, []string{"a", "b"}},
+		{"single line without newline", "only", 3, []string{"only"}},
+		{"empty lines are kept", "a\n\nb\n", 3, []string{"a", "", "b"}},
+		{"across read chunks", strings.Join(long, "\n") + "\n", 1500, long[500:]},
+		{"across read chunks, no trailing newline", strings.Join(long, "\n"), 700, long[1300:]},
+		{"whole file across read chunks", strings.Join(long, "\n") + "\n", 5000, long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "server.log")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			lines, offset, err := TailLog(path, tt.n)
+			if err != nil {
+				t.Fatalf("TailLog: %v", err)
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("TailLog(%d) = %d lines %q..., want %d lines", tt.n, len(lines), head(lines), len(tt.want))
+			}
+			if offset != int64(len(tt.content)) {
+				t.Errorf("TailLog offset = %d, want the file size %d", offset, len(tt.content))
+			}
+		})
+	}
+}
+
+func TestTailLogMissingFile(t *testing.T) {
+	lines, offset, err := TailLog(filepath.Join(t.TempDir(), "missing.log"), 10)
+	if err != nil || len(lines) != 0 || offset != 0 {
+		t.Errorf("TailLog(missing) = %q, %d, %v, want no lines at offset 0", lines, offset, err)
+	}
+}
+
+// The offset TailLog returns is where ReadLogSince picks up, without gaps.
+func TestTailLogThenReadLogSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, offset, err := TailLog(path, 1)
+	if err != nil {
+		t.Fatalf("TailLog: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("c\nd\n")
+	_ = f.Close()
+
+	lines, err := ReadLogSince(path, offset, 1<<20)
+	if err != nil {
+		t.Fatalf("ReadLogSince: %v", err)
+	}
+	if want := []string{"c", "d"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("ReadLogSince = %q, want %q", lines, want)
+	}
+}
+
+func head(lines []string) []string {
+	if len(lines) > 3 {
+		return lines[:3]
+	}
+	return lines
+}
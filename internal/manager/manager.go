@@ -1,157 +1,680 @@
 package manager
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 	"time"
 )
 
-func (m *Manager) Start(cfg ServerConfig, logPath string) (ServerState, error) {
+// Start launches a server. trigger says what asked for it (see TriggerAPI)
+// and is kept in the run history.
+func (m *Manager) Start(cfg ServerConfig, logPath string, trigger string) (ServerState, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if p, ok := m.procs[cfg.Name]; ok && p.state.Running {
-		return p.state, fmt.Errorf("%s already running (pid=%d)", cfg.Name, p.state.PID)
+	if p, ok := m.procs[cfg.Name]; ok {
+		if p.state.Running {
+			return p.state, fmt.Errorf("%s already running (pid=%d)", cfg.Name, p.state.PID)
+		}
+		if p.state.State == StateQueued {
+			st := p.state
+			st.QueuePosition = m.queuePosition(p)
+			return st, fmt.Errorf("%s is already queued to start (position %d)", cfg.Name, st.QueuePosition)
+		}
+		// A manual start supersedes any pending automatic restart
+		p.cancelRestart()
+	}
+
+	p := &managedProc{
+		cfg:     cfg,
+		logPath: logPath,
+		state:   ServerState{Name: cfg.Name, State: StateStopped},
+	}
+	m.procs[cfg.Name] = p
+
+	err := m.launch(p, "started", trigger)
+	st := p.state
+	st.QueuePosition = m.queuePosition(p)
+	return st, err
+}
+
+// errStartCancelled is returned by launchNow when a stop called off the start
+// while pre_start hooks ran.
+var errStartCancelled = errors.New("start cancelled by a stop request")
+
+// launch starts p now if a start slot is free and queues it otherwise.
+// Caller must hold m.mu; it is released while hooks run.
+func (m *Manager) launch(p *managedProc, reason string, trigger string) error {
+	if !m.startSlotFree() {
+		return m.enqueueStart(p, reason, trigger)
+	}
+	return m.launchNow(p, reason, trigger)
+}
+
+// launchNow runs p's pre_start hooks, spawns it and moves it to starting
+// (when it has a health check to pass) or running, or to failed if a hook or
+// the spawn failed. Caller must hold m.mu; it is released while hooks run.
+func (m *Manager) launchNow(p *managedProc, reason string, trigger string) error {
+	p.slotSince = time.Now()
+	p.state.PID = 0
+	p.state.RunID = ""
+	p.trigger = trigger
+	p.startCancelled = false
+
+	if len(p.cfg.Hooks.PreStart) > 0 {
+		// starting counts as running, so concurrent starts are refused meanwhile
+		_ = p.transition(StateStarting, reason+", running pre_start hooks")
+		m.mu.Unlock()
+		err := m.runHooks(p, HookPreStart, nil)
+		m.mu.Lock()
+		if p.startCancelled {
+			p.startCancelled = false
+			_ = p.transition(StateStopped, "start cancelled during pre_start hooks")
+			return errStartCancelled
+		}
+		if err != nil {
+			p.state.LastError = err.Error()
+			_ = p.transition(StateFailed, fmt.Sprintf("start aborted: %v", err))
+			m.recordRun(p)
+			return err
+		}
+	}
+
+	if err := m.spawn(p); err != nil {
+		p.state.LastError = err.Error()
+		_ = p.transition(StateFailed, fmt.Sprintf("start failed: %v", err))
+		m.recordRun(p)
+		return err
 	}
+	m.runHooksAsync(p, nil, HookPostStart)
+
+	if p.cfg.Health.Type != "" {
+		return p.transition(StateStarting, reason+", waiting for health check")
+	}
+	return p.transition(StateRunning, reason)
+}
+
+// spawn starts the process for p and its reaper. Caller must hold m.mu.
+func (m *Manager) spawn(p *managedProc) error {
+	cfg := p.cfg
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	cmd := cfg.RunAs.command(ctx, cfg.Command, cfg.Args...)
 	cmd.Dir = cfg.Cwd
-	cmd.Env = append(os.Environ(), cfg.Env...)
+	token := newRunToken()
+	cmd.Env = append(append(os.Environ(), cfg.Env...), runTokenEnv+"="+token)
 
 	// Put the process into its own process group (Unix)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cfg.Sandbox.Enabled {
+		// The sandbox init drops privileges itself once its mounts are set up
+		if err := m.sandbox(cmd, cfg); err != nil {
+			cancel()
+			return err
+		}
+	} else {
+		cfg.RunAs.apply(cmd)
+	}
+
+	// Resource limits: clone the process straight into its own cgroup
+	cgroupPath := ""
+	if !cfg.Resources.IsZero() {
+		path, release, err := m.attachCgroup(cmd, cfg.Name, cfg.Resources)
+		if err != nil {
+			cancel()
+			return err
+		}
+		defer release()
+		cgroupPath = path
+	}
 
 	// Logs
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	logFile, err := os.OpenFile(p.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		cancel()
-		return ServerState{}, err
+		removeCgroup(cgroupPath)
+		return err
+	}
+	// stdin control: through a pipe, or the terminal in pty mode. Both are
+	// pollable files, so writes to them can time out (see writeStdin).
+	var stdin, stdinChild *os.File
+	var ptyMaster, ptySlave *os.File
+	if cfg.Pty.Enabled {
+		ptyMaster, ptySlave, err = openPty(cfg.Pty)
+		if err != nil {
+			cancel()
+			_ = logFile.Close()
+			removeCgroup(cgroupPath)
+			return err
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = ptySlave, ptySlave, ptySlave
+		// A new session with the terminal as controlling TTY; its process
+		// group is the session's, so Setpgid must be off
+		cmd.SysProcAttr.Setpgid = false
+		cmd.SysProcAttr.Setsid = true
+		cmd.SysProcAttr.Setctty = true
+		cmd.SysProcAttr.Ctty = 0
+		stdin = ptyMaster
+	} else {
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		stdinChild, stdin, err = os.Pipe()
+		if err != nil {
+			cancel()
+			_ = logFile.Close()
+			removeCgroup(cgroupPath)
+			return err
+		}
+		cmd.Stdin = stdinChild
 	}
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
 
-	// Optional stdin control
-	stdinPipe, err := cmd.StdinPipe()
+	// Before the start, so an OOM kill right away still counts
+	oom := readOOMBaseline(cgroupPath)
+
+	err = cmd.Start()
+	if ptySlave != nil {
+		// the child has its own copies now
+		_ = ptySlave.Close()
+	}
+	if stdinChild != nil {
+		_ = stdinChild.Close()
+	}
 	if err != nil {
 		cancel()
+		_ = stdin.Close()
 		_ = logFile.Close()
-		return ServerState{}, err
+		removeCgroup(cgroupPath)
+		return err
 	}
 
-	if err := cmd.Start(); err != nil {
-		cancel()
-		_ = logFile.Close()
-		return ServerState{}, err
+	var ptyOut *ptyOutput
+	if ptyMaster != nil {
+		ptyOut = startPtyOutput(ptyMaster, logFile, cfg.Pty.StripANSI)
 	}
 
-	p := &managedProc{
-		cfg:    cfg,
-		cmd:    cmd,
-		state:  ServerState{Name: cfg.Name, Running: true, PID: cmd.Process.Pid, StartedAt: time.Now()},
-		stdin:  bufio.NewWriter(stdinPipe),
-		cancel: cancel,
+	p.cmd = cmd
+	p.stdin = stdin
+	p.cancel = cancel
+	p.stopReason = ""
+	p.stopStep = ""
+	p.stopTrigger = ""
+	p.killed = false
+	p.state.Adopted = false
+	p.state.StoppedBy = ""
+	p.state.PID = cmd.Process.Pid
+	p.state.StartedAt = time.Now()
+	p.state.RunID = newRunID(p.state.StartedAt, p.state.PID)
+	p.state.ExitedAt = time.Time{}
+	p.state.ExitCode = 0
+	p.state.LastError = ""
+	p.state.ExitSignal = ""
+	p.state.CoreDumped = false
+	p.state.OOMKilled = false
+	p.state.ExitLogTail = nil
+	p.oomBaseline = oom
+	p.state.NextRestartAt = time.Time{}
+	p.state.Cgroup = cgroupPath
+	p.state.Processes = nil
+	p.leftovers = ""
+
+	// Persist a run record so a restarted agent can re-adopt the process (best effort)
+	p.procStartTime = 0
+	if st, err := readProcStat(p.state.PID); err == nil {
+		p.procStartTime = st.StartTime
+		_ = m.saveRunRecord(runRecord{
+			Name:          cfg.Name,
+			RunID:         p.state.RunID,
+			Trigger:       p.trigger,
+			PID:           p.state.PID,
+			StartedAt:     p.state.StartedAt,
+			ProcStartTime: st.StartTime,
+			LogPath:       p.logPath,
+			Cgroup:        cgroupPath,
+			RunToken:      token,
+			Pty:           cfg.Pty.Enabled,
+		})
 	}
+	p.tree = newProcessTracker(p.state.PID, p.procStartTime, token, cgroupPath)
 
-	m.procs[cfg.Name] = p
+	m.watchRun(p)
 
 	// Reap process asynchronously
-	go func() {
-		err := cmd.Wait()
-		exitCode := 0
-		if err != nil {
-			// best-effort exit code extraction
-			if ee := new(exec.ExitError); errors.As(err, &ee) {
-				if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
-					exitCode = ws.ExitStatus()
-				} else {
-					exitCode = 1
-				}
+	go m.reap(p, cmd, logFile, stdin, ptyOut)
+
+	return nil
+}
+
+func (m *Manager) reap(p *managedProc, cmd *exec.Cmd, logFile *os.File, stdin *os.File, ptyOut *ptyOutput) {
+	err := cmd.Wait()
+	if ptyOut != nil {
+		// the master is the stdin too
+		ptyOut.close()
+	} else {
+		// also ends a console write still waiting on a child that stopped reading
+		_ = stdin.Close()
+	}
+	leftovers := p.tree.killLeftovers()
+	exitCode := 0
+	if err != nil {
+		// best-effort exit code extraction
+		if ee := new(exec.ExitError); errors.As(err, &ee) {
+			if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
+				exitCode = ws.ExitStatus()
 			} else {
 				exitCode = 1
 			}
+		} else {
+			exitCode = 1
 		}
+	}
 
-		m.mu.Lock()
-		defer m.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		p.state.Running = false
-		p.state.ExitedAt = time.Now()
-		p.state.ExitCode = exitCode
-		if err != nil {
-			p.state.LastError = err.Error()
+	_ = logFile.Close()
+	p.leftovers = leftovers
+	m.exited(p, exitCode, err)
+}
+
+// exited records the end of p's process and applies the restart policy.
+// Caller must hold m.mu.
+func (m *Manager) exited(p *managedProc, exitCode int, err error) {
+	p.state.ExitedAt = time.Now()
+	p.state.ExitCode = exitCode
+	m.recordExitDiagnostics(p, err)
+
+	m.removeRunRecord(p.cfg.Name)
+	removeCgroup(p.state.Cgroup)
+	close(p.done)
+	pruneLogsAsync(p)
+
+	p.state.Health = ""
+	p.state.Processes = nil
+	if err != nil {
+		p.state.LastError = err.Error()
+	}
+
+	if p.state.State == StateStopping {
+		p.state.StoppedBy = p.stopStep
+	}
+
+	var next LifecycleState
+	var reason string
+	switch {
+	case p.state.State == StateStopping && p.killed:
+		next, reason = StateKilled, p.stopReason
+	case p.state.State == StateStopping:
+		next, reason = StateStopped, p.stopReason
+	case err == nil:
+		next, reason = StateExited, "exited with code 0"
+	default:
+		next, reason = StateCrashed, "exited unexpectedly: "+exitDescription(p.state, err)
+	}
+	if p.leftovers != "" {
+		reason += "; " + p.leftovers
+	}
+	_ = p.transition(next, reason)
+
+	m.recordRun(p)
+
+	if p.state.State == StateCrashed {
+		m.runHooksAsync(p, &exitCode, HookOnCrash, HookPostStop)
+	} else {
+		m.runHooksAsync(p, &exitCode, HookPostStop)
+	}
+
+	if m.procs[p.cfg.Name] == p && p.shouldRestart() {
+		if m.cordoned {
+			p.state.StateReason += "; not restarted while the agent is cordoned"
+		} else {
+			m.scheduleRestart(p)
 		}
-		_ = logFile.Close()
-	}()
+	}
+	m.kickStartQueue()
+}
+
+// Adopt re-attaches to a process started by a previous agent run, using the
+// run record persisted at start. It reports false if there was nothing to
+// adopt (no record, or the recorded process is gone).
+func (m *Manager) Adopt(cfg ServerConfig, logPath string) (ServerState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.procs[cfg.Name]; ok && p.state.Running {
+		return p.state, false, fmt.Errorf("%s already running (pid=%d)", cfg.Name, p.state.PID)
+	}
+
+	rec, ok, err := m.loadRunRecord(cfg.Name)
+	if err != nil || !ok {
+		return ServerState{}, false, err
+	}
+	if !processAlive(rec.PID, rec.ProcStartTime) {
+		m.removeRunRecord(cfg.Name)
+		m.recordLostRun(rec)
+		if rec.Pty {
+			return ServerState{}, false, fmt.Errorf("%s ran on a pty and got a hangup when the previous agent exited; pty instances don't survive agent restarts", cfg.Name)
+		}
+		return ServerState{}, false, nil
+	}
+
+	if rec.LogPath != "" {
+		logPath = rec.LogPath
+	}
+
+	if rec.RunID == "" {
+		rec.RunID = newRunID(rec.StartedAt, rec.PID)
+	}
+	if rec.Trigger == "" {
+		rec.Trigger = TriggerAdopted
+	}
+
+	p := &managedProc{
+		cfg:           cfg,
+		logPath:       logPath,
+		procStartTime: rec.ProcStartTime,
+		oomBaseline:   readOOMBaseline(rec.Cgroup),
+		tree:          newProcessTracker(rec.PID, rec.ProcStartTime, rec.RunToken, rec.Cgroup),
+		trigger:       rec.Trigger,
+		state: ServerState{
+			Name:      cfg.Name,
+			State:     StateStopped,
+			Adopted:   true,
+			PID:       rec.PID,
+			RunID:     rec.RunID,
+			StartedAt: rec.StartedAt,
+			Cgroup:    rec.Cgroup,
+		},
+	}
+	m.procs[cfg.Name] = p
+	m.watchRun(p)
+	_ = p.transition(StateRunning, "re-adopted after agent restart")
+
+	// Not our child, so we cannot wait(2) on it: poll /proc instead
+	go m.watchAdopted(p)
+
+	return p.state, true, nil
+}
+
+func (m *Manager) watchAdopted(p *managedProc) {
+	pid := p.state.PID
+	for processAlive(pid, p.procStartTime) {
+		time.Sleep(time.Second)
+	}
+	leftovers := p.tree.killLeftovers()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.leftovers = leftovers
+
+	m.exited(p, -1, errors.New("exit status unknown (process was adopted after an agent restart)"))
+}
+
+// watchRun starts the per-run helpers (log rotation) that live until p.done
+// is closed. Caller must hold m.mu.
+func (m *Manager) watchRun(p *managedProc) {
+	p.done = make(chan struct{})
+	go m.trackProcesses(p, p.tree, p.done)
+	go m.sampleMetrics(p, p.done)
+	if p.cfg.Logs.enabled() {
+		go m.rotateLogs(p.logPath, p.cfg.Logs, p.done)
+	}
+	pruneLogsAsync(p)
+
+	p.state.Health = ""
+	p.state.HealthCheckedAt = time.Time{}
+	p.state.HealthMessage = ""
+	if p.cfg.Health.Type != "" {
+		p.state.Health = HealthStarting
+		if p.state.Adopted {
+			// it was running before the agent restarted; don't wait for a fresh start
+			p.state.Health = HealthReady
+		}
+		go m.probeHealth(p, p.done)
+	}
+}
 
-	return p.state, nil
+// shouldRestart applies the restart policy to a process that just ended.
+// Stops requested through the manager never restart.
+func (p *managedProc) shouldRestart() bool {
+	switch p.cfg.Restart.Mode {
+	case RestartAlways:
+		return p.state.State == StateExited || p.state.State == StateCrashed
+	case RestartOnFailure:
+		return p.state.State == StateCrashed
+	default:
+		return false
+	}
+}
+
+// scheduleRestart arms a backoff timer for p, or flags a crash loop when the
+// policy's restart budget for the window is used up. Caller must hold m.mu.
+func (m *Manager) scheduleRestart(p *managedProc) {
+	pol := p.cfg.Restart
+	now := time.Now()
+
+	p.restartTimes = recentRestarts(pol, p.restartTimes, now)
+	if crashLooping(pol, len(p.restartTimes)) {
+		p.state.NextRestartAt = time.Time{}
+		_ = p.transition(StateCrashLoop, fmt.Sprintf("gave up after %d restarts within %s", len(p.restartTimes), pol.Window))
+		return
+	}
+
+	delay := restartDelay(pol, len(p.restartTimes))
+	p.state.NextRestartAt = now.Add(delay)
+	p.restartTimer = time.AfterFunc(delay, func() { m.restart(p) })
+	_ = p.transition(StateBackoff, fmt.Sprintf("restarting in %s", delay))
+}
+
+func (m *Manager) restart(p *managedProc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.procs[p.cfg.Name] != p || p.state.State != StateBackoff {
+		return
+	}
+	p.restartTimer = nil
+	p.state.NextRestartAt = time.Time{}
+	if m.cordoned {
+		_ = p.transition(StateStopped, "automatic restart skipped: the agent is cordoned")
+		return
+	}
+	p.restartTimes = append(p.restartTimes, time.Now())
+
+	if err := m.launch(p, fmt.Sprintf("automatic restart #%d", p.state.Restarts+1), TriggerRestartPolicy); err != nil {
+		if !errors.Is(err, errStartCancelled) {
+			m.scheduleRestart(p)
+		}
+		return
+	}
+	p.state.Restarts++
+}
+
+// SetCordoned turns the manager's own restarts (restart policy and health
+// checks) off while the agent is cordoned, and back on. Servers that would
+// have been restarted meanwhile stay down.
+func (m *Manager) SetCordoned(cordoned bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cordoned = cordoned
+}
+
+func (p *managedProc) cancelRestart() {
+	if p.restartTimer != nil {
+		p.restartTimer.Stop()
+		p.restartTimer = nil
+	}
+	p.state.NextRestartAt = time.Time{}
+}
+
+// recentRestarts returns the restart times still inside the policy's window
+// (all of them without a window), reusing times.
+func recentRestarts(pol RestartPolicy, times []time.Time, now time.Time) []time.Time {
+	if pol.Window <= 0 {
+		return times
+	}
+	recent := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < pol.Window {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+// crashLooping reports whether n restarts in the window use up the policy's
+// budget; MaxRestarts 0 never does.
+func crashLooping(pol RestartPolicy, n int) bool {
+	return pol.MaxRestarts > 0 && n >= pol.MaxRestarts
+}
+
+// restartDelay doubles the base backoff for every restart already made in the
+// current window, capped at MaxBackoff. Without a cap it stops doubling
+// before the delay would overflow.
+func restartDelay(pol RestartPolicy, n int) time.Duration {
+	d := pol.Backoff
+	if d <= 0 {
+		d = time.Second
+	}
+	for i := 0; i < n; i++ {
+		if d > math.MaxInt64/2 {
+			break
+		}
+		d *= 2
+		if pol.MaxBackoff > 0 && d >= pol.MaxBackoff {
+			return pol.MaxBackoff
+		}
+	}
+	if pol.MaxBackoff > 0 && d > pol.MaxBackoff {
+		return pol.MaxBackoff
+	}
+	return d
 }
 
-func (m *Manager) Stop(name string) (ServerState, error) {
+// Stop runs the server's stop chain and waits for it to exit. trigger is
+// kept in the run history.
+func (m *Manager) Stop(name string, trigger string) (ServerState, error) {
 	m.mu.Lock()
 	p, ok := m.procs[name]
 	if !ok {
 		m.mu.Unlock()
 		return ServerState{}, fmt.Errorf("unknown server: %s", name)
 	}
-	if !p.state.Running || p.cmd.Process == nil {
+	if p.state.State == StateBackoff {
+		// Waiting out a restart backoff: stopping means cancelling it
+		p.cancelRestart()
+		_ = p.transition(StateStopped, "pending restart cancelled")
+		state := p.state
+		m.mu.Unlock()
+		return state, nil
+	}
+	if p.state.State == StateQueued {
+		m.dequeueStart(p)
+		_ = p.transition(StateStopped, "queued start cancelled")
+		state := p.state
+		m.mu.Unlock()
+		return state, nil
+	}
+	if p.state.State == StateStopping {
+		state := p.state
+		m.mu.Unlock()
+		return state, fmt.Errorf("%s is already stopping", name)
+	}
+	if !p.state.Running {
 		state := p.state
 		m.mu.Unlock()
 		return state, fmt.Errorf("%s is not running", name)
 	}
+	if p.state.PID == 0 {
+		// Still running pre_start hooks: call the start off once they are done
+		p.startCancelled = true
+		m.mu.Unlock()
+		for m.inPreStart(p) {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return m.Status(name), nil
+	}
 
 	// Snapshot values we need without holding lock too long
-	stopCfg := p.cfg.Stop
-	pid := p.cmd.Process.Pid
+	_ = p.transition(StateStopping, "stop requested")
+	p.stopReason = "stopped on request"
+	p.stopTrigger = trigger
+	p.stopStep = ""
+	p.killed = false
+	steps := stopSteps(p.cfg.Stop, p.stdin == nil)
+	pid := p.state.PID
 	m.mu.Unlock()
 
-	// Attempt graceful stop
-	switch stopCfg.Type {
-	case StopStdin:
-		if stopCfg.StdinCommand == "" {
-			stopCfg.StdinCommand = "stop\n"
-		}
-		_, _ = p.stdin.WriteString(stopCfg.StdinCommand)
-		_ = p.stdin.Flush()
+	if err := m.runHooks(p, HookPreStop, nil); err != nil {
+		// A failed pre_stop hook must not keep the server from stopping
+		m.setStopReason(p, "", "stop requested (pre_stop hook failed)", false)
+	}
 
-	case StopSignal:
-		if stopCfg.Signal == 0 {
-			stopCfg.Signal = syscall.SIGTERM
-		}
-		// kill process group: negative PID
-		_ = syscall.Kill(-pid, stopCfg.Signal)
+	m.runStopSteps(p, steps, pid)
+	return m.Status(name), nil
+}
 
-	default:
-		_ = syscall.Kill(-pid, syscall.SIGTERM)
-	}
+// inPreStart reports whether p is still running pre_start hooks.
+func (m *Manager) inPreStart(p *managedProc) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.procs[p.cfg.Name] == p && p.state.State == StateStarting && p.state.PID == 0
+}
 
-	grace := stopCfg.GracePeriod
-	if grace == 0 {
-		grace = 10 * time.Second
+// SendInput writes a console command to the server's stdin, adding the
+// trailing newline if missing.
+func (m *Manager) SendInput(name string, text string) error {
+	m.mu.Lock()
+	p, ok := m.procs[name]
+	running := ok && p.state.Running
+	m.mu.Unlock()
+	if !running {
+		return fmt.Errorf("%s is not running", name)
 	}
-
-	// Wait for exit
-	deadline := time.Now().Add(grace)
-	for time.Now().Before(deadline) {
-		if !m.IsRunning(name) {
-			return m.Status(name), nil
-		}
-		time.Sleep(200 * time.Millisecond)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
 	}
+	return m.writeStdin(p, text)
+}
 
-	// Force kill
-	_ = syscall.Kill(-pid, syscall.SIGKILL)
+// stdinWriteTimeout bounds how long a console write waits for the server to
+// read its input. The agent's console wait is sized around it, so keep the
+// two below the command server's 10s console timeout.
+const stdinWriteTimeout = 5 * time.Second
 
-	// Give it a moment to settle
-	time.Sleep(250 * time.Millisecond)
+// writeStdin writes text to p's stdin. The write happens outside m.mu and
+// gives up after stdinWriteTimeout, so a server that stops reading its
+// console only holds up its own writers.
+func (m *Manager) writeStdin(p *managedProc, text string) error {
+	m.mu.Lock()
+	running, stdin := p.state.Running, p.stdin
+	m.mu.Unlock()
 
-	return m.Status(name), nil
+	if !running {
+		return fmt.Errorf("%s is not running", p.cfg.Name)
+	}
+	if stdin == nil {
+		return fmt.Errorf("%s has no stdin (re-adopted after an agent restart)", p.cfg.Name)
+	}
+
+	p.stdinMu.Lock()
+	defer p.stdinMu.Unlock()
+
+	_ = stdin.SetWriteDeadline(time.Now().Add(stdinWriteTimeout))
+	if _, err := stdin.WriteString(text); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("write stdin: %s is not reading its console input", p.cfg.Name)
+		}
+		return fmt.Errorf("write stdin: %w", err)
+	}
+	return nil
 }
 
 func (m *Manager) IsRunning(name string) bool {
@@ -162,12 +685,40 @@ func (m *Manager) IsRunning(name string) bool {
 }
 
 func (m *Manager) Status(name string) ServerState {
+	m.mu.Lock()
+	p, ok := m.procs[name]
+	if !ok {
+		m.mu.Unlock()
+		return ServerState{Name: name, Running: false, State: StateStopped}
+	}
+	st := p.state
+	st.QueuePosition = m.queuePosition(p)
+	if last, ok := p.metrics.last(); ok && st.Running {
+		st.Metrics = &last
+	}
+	logPath := p.logPath
+	m.mu.Unlock()
+
+	// Touches the filesystem, so not under the lock
+	st.LogSegments, _ = ListLogSegments(logPath)
+	return st
+}
+
+// Remove forgets a stopped server, cancelling any pending automatic restart.
+func (m *Manager) Remove(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if p, ok := m.procs[name]; ok {
-		return p.state
+	p, ok := m.procs[name]
+	if !ok {
+		return nil
+	}
+	if p.state.Running {
+		return fmt.Errorf("%s is running", name)
 	}
-	return ServerState{Name: name, Running: false}
+	p.cancelRestart()
+	m.dequeueStart(p)
+	delete(m.procs, name)
+	return nil
 }
 
 func (m *Manager) List() []ServerState {
@@ -175,7 +726,9 @@ func (m *Manager) List() []ServerState {
 	defer m.mu.Unlock()
 	out := make([]ServerState, 0, len(m.procs))
 	for _, p := range m.procs {
-		out = append(out, p.state)
+		st := p.state
+		st.QueuePosition = m.queuePosition(p)
+		out = append(out, st)
 	}
 	return out
 }
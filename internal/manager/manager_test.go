@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	pol := RestartPolicy{Backoff: 5 * time.Second, MaxBackoff: time.Minute}
+	tests := []struct {
+		name string
+		pol  RestartPolicy
+		n    int
+		want time.Duration
+	}{
+		{"first restart", pol, 0, 5 * time.Second},
+		{"doubles", pol, 1, 10 * time.Second},
+		{"doubles again", pol, 2, 20 * time.Second},
+		{"below the cap", pol, 3, 40 * time.Second},
+		{"reaches the cap", pol, 4, time.Minute},
+		{"stays at the cap", pol, 50, time.Minute},
+		{"cap equal to a doubled value", RestartPolicy{Backoff: time.Second, MaxBackoff: 4 * time.Second}, 2, 4 * time.Second},
+		{"backoff above the cap", RestartPolicy{Backoff: 2 * time.Minute, MaxBackoff: time.Minute}, 0, time.Minute},
+		{"default backoff", RestartPolicy{}, 0, time.Second},
+		{"default backoff doubles", RestartPolicy{MaxBackoff: time.Hour}, 3, 8 * time.Second},
+		{"no cap", RestartPolicy{Backoff: time.Second}, 10, 1024 * time.Second},
+		{"no cap does not overflow", RestartPolicy{Backoff: time.Second}, 1000, time.Second << 33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restartDelay(tt.pol, tt.n); got != tt.want {
+				t.Errorf("restartDelay(%+v, %d) = %s, want %s", tt.pol, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartDelayNeverNegative(t *testing.T) {
+	for _, b := range []time.Duration{1, time.Millisecond, 3 * time.Second, math.MaxInt64 / 3} {
+		for n := 0; n < 200; n++ {
+			if d := restartDelay(RestartPolicy{Backoff: b}, n); d <= 0 {
+				t.Fatalf("restartDelay(backoff %s, %d) = %s", b, n, d)
+			}
+		}
+	}
+}
+
+func TestCrashLoopWindow(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ago := func(ds ...time.Duration) []time.Time {
+		var out []time.Time
+		for _, d := range ds {
+			out = append(out, now.Add(-d))
+		}
+		return out
+	}
+	window := RestartPolicy{MaxRestarts: 3, Window: 10 * time.Minute}
+
+	tests := []struct {
+		name       string
+		pol        RestartPolicy
+		times      []time.Time
+		wantRecent int
+		wantLoop   bool
+	}{
+		{"no restarts yet", window, nil, 0, false},
+		{"below the budget", window, ago(time.Minute, 2*time.Minute), 2, false},
+		{"budget used up", window, ago(time.Minute, 2*time.Minute, 3*time.Minute), 3, true},
+		{"old restarts fall out of the window", window, ago(time.Minute, 11*time.Minute, 20*time.Minute), 1, false},
+		{"window edge is outside", window, ago(10*time.Minute, time.Minute, 2*time.Minute), 2, false},
+		{"just inside the window", window, ago(10*time.Minute-time.Second, time.Minute, 2*time.Minute), 3, true},
+		{"no window counts every restart", RestartPolicy{MaxRestarts: 3}, ago(time.Hour, 24*time.Hour, 48*time.Hour), 3, true},
+		{"max_restarts 0 is unlimited", RestartPolicy{MaxRestarts: 0, Window: 10 * time.Minute}, ago(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 10, false},
+		{"max_restarts 0 without a window", RestartPolicy{}, ago(time.Hour, 2*time.Hour, 3*time.Hour), 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recent := recentRestarts(tt.pol, tt.times, now)
+			if len(recent) != tt.wantRecent {
+				t.Errorf("recentRestarts kept %d, want %d", len(recent), tt.wantRecent)
+			}
+			if got := crashLooping(tt.pol, len(recent)); got != tt.wantLoop {
+				t.Errorf("crashLooping(%+v, %d) = %v, want %v", tt.pol, len(recent), got, tt.wantLoop)
+			}
+		})
+	}
+}
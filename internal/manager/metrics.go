@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	metricsInterval = 10 * time.Second
+	metricsHistory  = 360 // one hour at metricsInterval
+
+	// clockTicks is USER_HZ, the unit of /proc CPU times (100 on every
+	// mainstream Linux platform).
+	clockTicks = 100
+)
+
+// ProcessMetrics is one sample of a server's whole process tree.
+type ProcessMetrics struct {
+	Time       time.Time
+	CPUPercent float64 // of one CPU, averaged since the previous sample
+	RSSBytes   int64
+	Threads    int
+	OpenFDs    int
+	ReadBytes  uint64 // cumulative storage reads
+	WriteBytes uint64 // cumulative storage writes
+	Processes  int
+	Uptime     time.Duration
+}
+
+// metricsRing keeps the most recent samples of a server.
+type metricsRing struct {
+	buf  []ProcessMetrics
+	next int
+	full bool
+}
+
+func (r *metricsRing) add(s ProcessMetrics) {
+	if r.buf == nil {
+		r.buf = make([]ProcessMetrics, metricsHistory)
+	}
+	r.buf[r.next] = s
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// samples returns the buffered samples, oldest first.
+func (r *metricsRing) samples() []ProcessMetrics {
+	if !r.full {
+		return append([]ProcessMetrics(nil), r.buf[:r.next]...)
+	}
+	out := make([]ProcessMetrics, 0, len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	return append(out, r.buf[:r.next]...)
+}
+
+func (r *metricsRing) last() (ProcessMetrics, bool) {
+	if !r.full && r.next == 0 {
+		return ProcessMetrics{}, false
+	}
+	i := (r.next - 1 + len(r.buf)) % len(r.buf)
+	return r.buf[i], true
+}
+
+// sampleMetrics records a sample of p's process tree every metricsInterval
+// until done is closed.
+func (m *Manager) sampleMetrics(p *managedProc, done <-chan struct{}) {
+	m.mu.Lock()
+	tree := p.tree
+	startedAt := p.state.StartedAt
+	m.mu.Unlock()
+
+	var prevTicks uint64
+	var prevTime time.Time
+
+	t := time.NewTicker(metricsInterval)
+	defer t.Stop()
+	for {
+		now := time.Now()
+		s, ticks := sampleTree(tree.scan())
+		s.Time = now
+		s.Uptime = now.Sub(startedAt).Truncate(time.Second)
+		if !prevTime.IsZero() && ticks >= prevTicks {
+			cpuSecs := float64(ticks-prevTicks) / clockTicks
+			s.CPUPercent = 100 * cpuSecs / now.Sub(prevTime).Seconds()
+		}
+		prevTicks, prevTime = ticks, now
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		m.mu.Lock()
+		p.metrics.add(s)
+		m.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// sampleTree sums resource usage over a run's processes and returns it with
+// their total CPU time in clock ticks.
+func sampleTree(procs []ProcessInfo) (ProcessMetrics, uint64) {
+	var s ProcessMetrics
+	var ticks uint64
+	pageSize := int64(os.Getpagesize())
+
+	for _, pr := range procs {
+		st, err := readProcStat(pr.PID)
+		if err != nil {
+			continue
+		}
+		s.Processes++
+		s.Threads += st.Threads
+		s.RSSBytes += st.RSSPages * pageSize
+		ticks += st.UTime + st.STime
+
+		if n, err := countFDs(pr.PID); err == nil {
+			s.OpenFDs += n
+		}
+		if r, w, err := readProcIO(pr.PID); err == nil {
+			s.ReadBytes += r
+			s.WriteBytes += w
+		}
+	}
+	return s, ticks
+}
+
+// Metrics returns the latest sample and the buffered history (oldest first)
+// of a server. The latest sample is nil when the server is not running.
+func (m *Manager) Metrics(name string) (*ProcessMetrics, []ProcessMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.procs[name]
+	if !ok {
+		return nil, []ProcessMetrics{}
+	}
+
+	var cur *ProcessMetrics
+	if last, ok := p.metrics.last(); ok && p.state.Running {
+		cur = &last
+	}
+	return cur, p.metrics.samples()
+}
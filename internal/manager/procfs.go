@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procStat holds the fields of /proc/<pid>/stat the manager cares about.
+type procStat struct {
+	State     string
+	PPID      int
+	PGID      int
+	UTime     uint64 // clock ticks
+	STime     uint64 // clock ticks
+	Threads   int
+	StartTime uint64 // clock ticks since boot
+	RSSPages  int64
+}
+
+func readProcStat(pid int) (procStat, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	// comm (field 2) may contain spaces and parens; fields resume after the last ')'
+	s := string(b)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 {
+		return procStat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(s[i+1:])
+	// fields[0] is field 3 (state), so field N is fields[N-3]
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("short /proc/%d/stat", pid)
+	}
+
+	st := procStat{State: fields[0]}
+	if st.PPID, err = strconv.Atoi(fields[1]); err != nil {
+		return procStat{}, fmt.Errorf("parse ppid of %d: %w", pid, err)
+	}
+	if st.PGID, err = strconv.Atoi(fields[2]); err != nil {
+		return procStat{}, fmt.Errorf("parse pgrp of %d: %w", pid, err)
+	}
+	if st.UTime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("parse utime of %d: %w", pid, err)
+	}
+	if st.STime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("parse stime of %d: %w", pid, err)
+	}
+	if st.Threads, err = strconv.Atoi(fields[17]); err != nil {
+		return procStat{}, fmt.Errorf("parse num_threads of %d: %w", pid, err)
+	}
+	if st.StartTime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("parse starttime of %d: %w", pid, err)
+	}
+	if st.RSSPages, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("parse rss of %d: %w", pid, err)
+	}
+
+	return st, nil
+}
+
+// listPIDs returns every PID currently visible in /proc.
+func listPIDs() ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			out = append(out, pid)
+		}
+	}
+	return out, nil
+}
+
+// countFDs returns the number of open file descriptors of pid.
+func countFDs(pid int) (int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// readProcIO returns the bytes pid caused to be read from / written to storage.
+func readProcIO(pid int) (read uint64, written uint64, err error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "read_bytes":
+			read = n
+		case "write_bytes":
+			written = n
+		}
+	}
+	return read, written, nil
+}
+
+// processAlive reports whether pid still refers to the process that was
+// started at startTime (guards against PID reuse).
+func processAlive(pid int, startTime uint64) bool {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return false
+	}
+	return st.StartTime == startTime && st.State != "Z"
+}
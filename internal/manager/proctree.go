@@ -0,0 +1,271 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// processScanInterval is how often a running server's process tree is
+	// rescanned for new descendants.
+	processScanInterval = 2 * time.Second
+
+	// leftoverWait bounds how long the end of a run waits for SIGKILLed
+	// leftover processes to disappear.
+	leftoverWait = 5 * time.Second
+
+	// runTokenEnv is set in a server's environment to a value unique to the
+	// run. Descendants inherit it, so processes that left the tree (a double
+	// fork with setsid re-parents them to init) are still recognised.
+	runTokenEnv = "RPM_RUN_TOKEN"
+
+	maxCommandLen = 256
+)
+
+// ProcessInfo is one process of a server's tree.
+type ProcessInfo struct {
+	PID      int
+	PPID     int
+	Command  string
+	Detached bool // left the server's process group, so group signals miss it
+}
+
+// processTracker follows every process of one run: the main process and its
+// descendants, whatever is in the run's cgroup, and processes carrying the
+// run token. Its own lock serializes scans.
+type processTracker struct {
+	mu        sync.Mutex
+	root      int
+	rootStart uint64 // nothing in the tree can be older than its root
+	token     string
+	cgroup    string
+	known     map[int]uint64 // pid -> start time of processes seen in the tree
+	foreign   map[int]uint64 // pid -> start time of processes checked for the token
+}
+
+func newProcessTracker(root int, rootStart uint64, token string, cgroup string) *processTracker {
+	return &processTracker{
+		root:      root,
+		rootStart: rootStart,
+		token:     token,
+		cgroup:    cgroup,
+		known:     map[int]uint64{},
+		foreign:   map[int]uint64{},
+	}
+}
+
+func newRunToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// scan returns the live processes of the run, sorted by PID. Once the main
+// process is gone it still finds the descendants seen earlier and any
+// process with the run token.
+func (t *processTracker) scan() []ProcessInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pids, err := listPIDs()
+	if err != nil {
+		return nil
+	}
+	self := os.Getpid()
+	stats := make(map[int]procStat, len(pids))
+	children := map[int][]int{}
+	for _, pid := range pids {
+		st, err := readProcStat(pid)
+		if err != nil || st.State == "Z" || st.StartTime < t.rootStart || pid == self {
+			continue
+		}
+		stats[pid] = st
+		children[st.PPID] = append(children[st.PPID], pid)
+	}
+
+	seen := map[int]bool{}
+	var queue []int
+	add := func(pid int) {
+		if _, ok := stats[pid]; ok && !seen[pid] {
+			seen[pid] = true
+			queue = append(queue, pid)
+		}
+	}
+	expand := func() {
+		for len(queue) > 0 {
+			pid := queue[0]
+			queue = queue[1:]
+			for _, c := range children[pid] {
+				add(c)
+			}
+		}
+	}
+
+	if st, ok := stats[t.root]; ok && st.StartTime == t.rootStart {
+		add(t.root)
+	}
+	for pid, start := range t.known {
+		if st, ok := stats[pid]; ok && st.StartTime == start {
+			add(pid)
+		}
+	}
+	if t.cgroup != "" {
+		if b, err := os.ReadFile(t.cgroup + "/cgroup.procs"); err == nil {
+			for _, f := range strings.Fields(string(b)) {
+				if pid, err := strconv.Atoi(f); err == nil {
+					add(pid)
+				}
+			}
+		}
+	}
+	expand()
+
+	// Whatever is still unaccounted for may have escaped the tree. The
+	// environment cannot be re-read cheaply, so each process is checked once.
+	foreign := make(map[int]uint64, len(t.foreign))
+	if t.token != "" {
+		for pid, st := range stats {
+			if seen[pid] {
+				continue
+			}
+			if start, ok := t.foreign[pid]; ok && start == st.StartTime {
+				foreign[pid] = start
+				continue
+			}
+			if hasEnv(pid, runTokenEnv, t.token) {
+				add(pid)
+			} else {
+				foreign[pid] = st.StartTime
+			}
+		}
+		expand()
+	}
+	t.foreign = foreign
+
+	known := make(map[int]uint64, len(seen))
+	out := make([]ProcessInfo, 0, len(seen))
+	for pid := range seen {
+		st := stats[pid]
+		known[pid] = st.StartTime
+		out = append(out, ProcessInfo{
+			PID:      pid,
+			PPID:     st.PPID,
+			Command:  readCommand(pid),
+			Detached: st.PGID != t.root,
+		})
+	}
+	t.known = known
+
+	sort.Slice(out, func(i, j int) bool { return out[i].PID < out[j].PID })
+	return out
+}
+
+// killLeftovers SIGKILLs whatever is left of the run once its main process
+// has exited, and waits up to leftoverWait for it to be gone (rescanning, so
+// processes forked meanwhile are caught too). It returns a note for the
+// state reason, or "" if nothing was left.
+func (t *processTracker) killLeftovers() string {
+	killed := map[int]bool{}
+	deadline := time.Now().Add(leftoverWait)
+	var left []ProcessInfo
+	for {
+		left = t.scan()
+		if len(left) == 0 || time.Now().After(deadline) {
+			break
+		}
+		for _, pr := range left {
+			_ = syscall.Kill(pr.PID, syscall.SIGKILL)
+			killed[pr.PID] = true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	switch {
+	case len(left) > 0:
+		pids := make([]string, len(left))
+		for i, pr := range left {
+			pids[i] = strconv.Itoa(pr.PID)
+		}
+		return fmt.Sprintf("%d leftover process(es) survived SIGKILL: %s", len(left), strings.Join(pids, ", "))
+	case len(killed) > 0:
+		return fmt.Sprintf("killed %d leftover process(es)", len(killed))
+	}
+	return ""
+}
+
+// trackProcesses rescans the run's process tree into p's state every
+// processScanInterval until done is closed.
+func (m *Manager) trackProcesses(p *managedProc, t *processTracker, done <-chan struct{}) {
+	tick := time.NewTicker(processScanInterval)
+	defer tick.Stop()
+	for {
+		procs := t.scan()
+
+		m.mu.Lock()
+		select {
+		case <-done:
+		default:
+			p.state.Processes = procs
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// signalDetached sends sig to the processes of p's current run that left its
+// process group, which a group signal misses.
+func (m *Manager) signalDetached(p *managedProc, sig syscall.Signal) {
+	m.mu.Lock()
+	t := p.tree
+	m.mu.Unlock()
+	if t == nil {
+		return
+	}
+	for _, pr := range t.scan() {
+		if pr.Detached {
+			_ = syscall.Kill(pr.PID, sig)
+		}
+	}
+}
+
+// hasEnv reports whether key=value is in pid's initial environment.
+func hasEnv(pid int, key string, value string) bool {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return false
+	}
+	// Entries are NUL-terminated; a leading NUL anchors the first one
+	env := append([]byte{0}, b...)
+	return bytes.Contains(env, []byte("\x00"+key+"="+value+"\x00"))
+}
+
+// readCommand returns pid's command line, or its name when that is empty.
+func readCommand(pid int) string {
+	var cmd string
+	if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		cmd = strings.TrimSpace(strings.ReplaceAll(string(b), "\x00", " "))
+	}
+	if cmd == "" {
+		if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+			cmd = "[" + strings.TrimSpace(string(b)) + "]"
+		}
+	}
+	if len(cmd) > maxCommandLen {
+		cmd = cmd[:maxCommandLen] + "..."
+	}
+	return cmd
+}
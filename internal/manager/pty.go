@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Pty runs a server on a pseudo-terminal held by the agent instead of
+// pipes, for programs that need a TTY (or only line-buffer on one). Output
+// still goes to the server log; stdin writes go through the terminal.
+type Pty struct {
+	Enabled   bool
+	StripANSI bool   // drop escape sequences (colors, cursor movement) from the log
+	Cols      uint16 // terminal size; 0 = 80x24
+	Rows      uint16
+}
+
+// ptyOutput copies a PTY master to the server log until the terminal is
+// gone.
+type ptyOutput struct {
+	master *os.File
+	done   chan struct{}
+}
+
+func startPtyOutput(master *os.File, log io.Writer, stripANSI bool) *ptyOutput {
+	o := &ptyOutput{master: master, done: make(chan struct{})}
+	if stripANSI {
+		log = &ansiStripper{w: log}
+	}
+	go func() {
+		defer close(o.done)
+		// Reads fail with EIO once no process has the terminal open any more
+		_, _ = io.Copy(log, master)
+	}()
+	return o
+}
+
+// close waits briefly for output still buffered in the terminal, then closes
+// the master. Children left holding the terminal get a hangup.
+func (o *ptyOutput) close() {
+	select {
+	case <-o.done:
+	case <-time.After(time.Second):
+	}
+	_ = o.master.Close()
+	<-o.done
+}
+
+// ansiStripper removes ANSI escape sequences (CSI, OSC and two or three
+// byte ESC sequences) from what it writes through, even when a sequence is
+// split across writes.
+type ansiStripper struct {
+	w     io.Writer
+	state ansiState
+}
+
+type ansiState int
+
+const (
+	ansiText   ansiState = iota
+	ansiEsc              // after ESC
+	ansiInter            // ESC followed by intermediate bytes, e.g. "ESC ( B"
+	ansiCSI              // after "ESC ["
+	ansiOSC              // after "ESC ]", up to BEL or "ESC \"
+	ansiOSCEsc           // ESC inside an OSC
+)
+
+func (s *ansiStripper) Write(b []byte) (int, error) {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch s.state {
+		case ansiText:
+			if c == 0x1b {
+				s.state = ansiEsc
+			} else {
+				out = append(out, c)
+			}
+		case ansiEsc:
+			switch {
+			case c == '[':
+				s.state = ansiCSI
+			case c == ']':
+				s.state = ansiOSC
+			case c >= 0x20 && c <= 0x2f:
+				s.state = ansiInter
+			default:
+				s.state = ansiText
+			}
+		case ansiInter:
+			if c < 0x20 || c > 0x2f {
+				s.state = ansiText
+			}
+		case ansiCSI:
+			if c >= 0x40 && c <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiOSC:
+			if c == 0x07 {
+				s.state = ansiText
+			} else if c == 0x1b {
+				s.state = ansiOSCEsc
+			}
+		case ansiOSCEsc:
+			s.state = ansiText
+		}
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
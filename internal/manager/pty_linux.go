@@ -0,0 +1,84 @@
+//go:build linux
+
+package manager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPty allocates a pseudo-terminal sized per cfg. Echo and the \n ->
+// \r\n output translation are turned off so the log reads like pipe output
+// and console commands don't show up twice.
+func openPty(cfg Pty) (master *os.File, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = master.Close()
+		}
+	}()
+
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		return nil, nil, fmt.Errorf("get pty number: %w", err)
+	}
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		return nil, nil, fmt.Errorf("unlock pty: %w", err)
+	}
+
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty slave: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = slave.Close()
+		}
+	}()
+
+	var t syscall.Termios
+	if err := ioctl(slave, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return nil, nil, fmt.Errorf("get pty attributes: %w", err)
+	}
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL
+	t.Oflag &^= syscall.ONLCR
+	if err := ioctl(slave, syscall.TCSETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return nil, nil, fmt.Errorf("set pty attributes: %w", err)
+	}
+
+	ws := struct{ Row, Col, X, Y uint16 }{Row: cfg.Rows, Col: cfg.Cols}
+	if ws.Row == 0 || ws.Col == 0 {
+		ws.Row, ws.Col = 24, 80
+	}
+	if err := ioctl(slave, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return nil, nil, fmt.Errorf("set pty size: %w", err)
+	}
+
+	return master, slave, nil
+}
+
+// ioctl goes through SyscallConn rather than Fd, which would switch the
+// file to blocking mode (a blocked read of the master could then not be
+// interrupted by closing it).
+func ioctl(f *os.File, req uint, arg uintptr) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
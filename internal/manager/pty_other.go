@@ -0,0 +1,12 @@
+//go:build !linux
+
+package manager
+
+import (
+	"fmt"
+	"os"
+)
+
+func openPty(cfg Pty) (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("pty mode requires Linux")
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestANSIStripper(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Done (3.2s)! For help, type \"help\"\r\n", "Done (3.2s)! For help, type \"help\"\r\n"},
+		{"utf-8", "héllo wörld ✓ 🎮 日本語\n", "héllo wörld ✓ 🎮 日本語\n"},
+		{"csi color", "\x1b[31mred\x1b[0m text", "red text"},
+		{"csi with params", "\x1b[1;32;40mgreen\x1b[m", "green"},
+		{"csi cursor", "a\x1b[2Kb\x1b[10;20Hc\x1b[?25ld", "abcd"},
+		{"osc title ended by bel", "\x1b]0;my server\x07ready", "ready"},
+		{"osc ended by st", "\x1b]0;title\x1b\\ready", "ready"},
+		{"osc hyperlink", "\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"two byte escape", "a\x1b=b\x1b>c\x1bMd", "abcd"},
+		{"charset escape", "\x1b(Bplain\x1b)0", "plain"},
+		{"colors around utf-8", "\x1b[33m⚠ warnung\x1b[0m ✓", "⚠ warnung ✓"},
+		{"trailing bare esc", "text\x1b", "text"},
+		{"unterminated csi", "text\x1b[31", "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := []byte(tt.in)
+
+			// In one write, a byte at a time and split at every position
+			chunkings := [][][]byte{{in}}
+			var bytewise [][]byte
+			for i := range in {
+				bytewise = append(bytewise, in[i:i+1])
+			}
+			chunkings = append(chunkings, bytewise)
+			for i := 1; i < len(in); i++ {
+				chunkings = append(chunkings, [][]byte{in[:i], in[i:]})
+			}
+
+			for _, chunks := range chunkings {
+				var out bytes.Buffer
+				s := &ansiStripper{w: &out}
+				for _, c := range chunks {
+					n, err := s.Write(c)
+					if err != nil || n != len(c) {
+						t.Fatalf("Write(%q) = %d, %v", c, n, err)
+					}
+				}
+				if got := out.String(); got != tt.want {
+					t.Errorf("written as %q: got %q, want %q", chunks, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// A sequence split across writes must not leak into the next write's text.
+func TestANSIStripperKeepsStateBetweenWrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string // output after each write
+	}{
+		{"esc at end of chunk", []string{"abc\x1b", "[31mdef"}, []string{"abc", "def"}},
+		{"csi split in params", []string{"a\x1b[1;3", "2mb"}, []string{"a", "b"}},
+		{"osc split", []string{"x\x1b]0;ti", "tle\x07y"}, []string{"x", "y"}},
+		{"osc st split between esc and backslash", []string{"x\x1b]0;t\x1b", "\\y"}, []string{"x", "y"}},
+		{"esc alone, then text", []string{"\x1b", "", "[0m", "ok"}, []string{"", "", "", "ok"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := &ansiStripper{w: &out}
+			for i, w := range tt.writes {
+				out.Reset()
+				if _, err := s.Write([]byte(w)); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				if got := out.String(); got != tt.want[i] {
+					t.Errorf("write %d (%q): got %q, want %q", i, w, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
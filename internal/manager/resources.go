@@ -0,0 +1,34 @@
+package manager
+
+// Resources are cgroup v2 limits for a server. The zero value means no
+// limits and no cgroup.
+type Resources struct {
+	MemoryMax       int64 // bytes, memory.max
+	MemoryHigh      int64 // bytes, memory.high
+	CPUWeight       int   // cpu.weight (1-10000)
+	CPUQuotaPercent int   // cpu.max as percent of one CPU (150 = 1.5 CPUs)
+	PidsMax         int   // pids.max
+	IOWeight        int   // io.weight (1-10000)
+}
+
+func (r Resources) IsZero() bool {
+	return r == Resources{}
+}
+
+// controllers lists the cgroup v2 controllers the limits need.
+func (r Resources) controllers() []string {
+	var out []string
+	if r.MemoryMax > 0 || r.MemoryHigh > 0 {
+		out = append(out, "memory")
+	}
+	if r.CPUWeight > 0 || r.CPUQuotaPercent > 0 {
+		out = append(out, "cpu")
+	}
+	if r.PidsMax > 0 {
+		out = append(out, "pids")
+	}
+	if r.IOWeight > 0 {
+		out = append(out, "io")
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// RunAs is the identity a server (and its hooks and exec probes) runs with.
+// The zero value inherits the agent's identity.
+type RunAs struct {
+	User   string // for display; empty = don't switch user
+	UID    uint32
+	GID    uint32
+	Groups []uint32 // supplementary groups
+
+	Umask string // octal, e.g. "0027"; empty = inherit
+}
+
+func (r RunAs) IsZero() bool {
+	return r.User == "" && r.Umask == ""
+}
+
+// command builds the exec.Cmd for name/args under r. A umask cannot be set
+// through SysProcAttr, so the command is wrapped in a shell that sets it and
+// execs the real program (same PID).
+func (r RunAs) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if r.Umask != "" {
+		args = append([]string{"-c", "umask " + r.Umask + ` && exec "$0" "$@"`, name}, args...)
+		name = "sh"
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// shell returns the sh -c script for a hook or probe command under r.
+func (r RunAs) shell(script string) string {
+	if r.Umask == "" {
+		return script
+	}
+	return "umask " + r.Umask + "\n" + script
+}
+
+// apply sets the process credentials on cmd; SysProcAttr must already be set.
+func (r RunAs) apply(cmd *exec.Cmd) {
+	if r.User == "" {
+		return
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    r.UID,
+		Gid:    r.GID,
+		Groups: r.Groups,
+		// Only root may call setgroups; an unprivileged agent can at most
+		// "switch" to itself
+		NoSetGroups: os.Geteuid() != 0,
+	}
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runRecord is persisted while a process runs so a restarted agent can
+// re-attach to it.
+type runRecord struct {
+	Name          string    `json:"name"`
+	RunID         string    `json:"run_id,omitempty"`
+	Trigger       string    `json:"trigger,omitempty"`
+	PID           int       `json:"pid"`
+	StartedAt     time.Time `json:"started_at"`
+	ProcStartTime uint64    `json:"proc_start_time"`
+	LogPath       string    `json:"log_path"`
+	Cgroup        string    `json:"cgroup,omitempty"`
+	RunToken      string    `json:"run_token,omitempty"`
+	Pty           bool      `json:"pty,omitempty"` // the process ran on a terminal held by the agent
+}
+
+func (m *Manager) runRecordPath(name string) string {
+	return filepath.Join(m.runDir, name+".json")
+}
+
+func (m *Manager) saveRunRecord(rec runRecord) error {
+	if m.runDir == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal run record: %w", err)
+	}
+	if err := os.MkdirAll(m.runDir, 0755); err != nil {
+		return fmt.Errorf("mkdir run dir: %w", err)
+	}
+
+	// Atomic write: write temp then rename
+	path := m.runRecordPath(rec.Name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("write temp run record: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename temp -> run record: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) loadRunRecord(name string) (runRecord, bool, error) {
+	if m.runDir == "" {
+		return runRecord{}, false, nil
+	}
+
+	b, err := os.ReadFile(m.runRecordPath(name))
+	if os.IsNotExist(err) {
+		return runRecord{}, false, nil
+	}
+	if err != nil {
+		return runRecord{}, false, fmt.Errorf("read run record: %w", err)
+	}
+
+	var rec runRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return runRecord{}, false, fmt.Errorf("parse run record %q: %w", m.runRecordPath(name), err)
+	}
+	return rec, true, nil
+}
+
+func (m *Manager) removeRunRecord(name string) {
+	if m.runDir == "" {
+		return
+	}
+	_ = os.Remove(m.runRecordPath(name))
+}
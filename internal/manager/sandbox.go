@@ -0,0 +1,37 @@
+package manager
+
+// Sandbox isolates a server in new PID, mount, IPC and UTS namespaces (Linux
+// only). The filesystem is read-only except for Writable paths, and the
+// server sees only its own processes.
+type Sandbox struct {
+	Enabled        bool
+	PrivateNetwork bool     // new network namespace with only loopback
+	Writable       []string // absolute paths bind-mounted read-write (the instance directory first)
+	Hostname       string   // defaults to the server name
+}
+
+// sandboxInitArg marks a re-exec of the agent binary as the sandbox init
+// process (see IsSandboxInit).
+const sandboxInitArg = "__rpm-sandbox-init"
+
+// sandboxSpec is what spawn hands to the init process.
+type sandboxSpec struct {
+	Path string   `json:"path"`
+	Args []string `json:"args"`
+
+	Root           string   `json:"root"` // empty directory used as the new root mountpoint
+	Writable       []string `json:"writable"`
+	PrivateNetwork bool     `json:"private_network"`
+	Hostname       string   `json:"hostname"`
+
+	// Credentials are dropped by init after the mounts are set up
+	UID    *uint32  `json:"uid,omitempty"`
+	GID    uint32   `json:"gid,omitempty"`
+	Groups []uint32 `json:"groups,omitempty"`
+}
+
+// IsSandboxInit reports whether the process was started as a sandbox init;
+// the agent must then call RunSandboxInit before doing anything else.
+func IsSandboxInit(args []string) bool {
+	return len(args) > 2 && args[1] == sandboxInitArg
+}
@@ -0,0 +1,298 @@
+//go:build linux
+
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// sandbox rewrites cmd to start the agent binary as init of a new set of
+// namespaces; init sets up the mounts, drops privileges and runs the real
+// command. cmd must not have credentials applied yet.
+func (m *Manager) sandbox(cmd *exec.Cmd, cfg ServerConfig) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%s: sandbox requires the agent to run as root", cfg.Name)
+	}
+
+	root, err := filepath.Abs(filepath.Join(m.runDir, "sandbox", cfg.Name))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("%s: sandbox root: %w", cfg.Name, err)
+	}
+
+	spec := sandboxSpec{
+		Path:           cmd.Path,
+		Args:           cmd.Args,
+		Root:           root,
+		Writable:       cfg.Sandbox.Writable,
+		PrivateNetwork: cfg.Sandbox.PrivateNetwork,
+		Hostname:       cfg.Sandbox.Hostname,
+	}
+	if spec.Hostname == "" {
+		spec.Hostname = cfg.Name
+	}
+	if r := cfg.RunAs; r.User != "" {
+		uid := r.UID
+		spec.UID, spec.GID, spec.Groups = &uid, r.GID, r.Groups
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%s: sandbox: %w", cfg.Name, err)
+	}
+	cmd.Path = self
+	cmd.Args = []string{"rpm-sandbox-init", sandboxInitArg, string(b)}
+
+	cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if spec.PrivateNetwork {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	return nil
+}
+
+// RunSandboxInit is the body of the sandbox init process (PID 1 of the new
+// PID namespace). It never returns: it exits with the server's exit code,
+// and the kernel kills anything left in the namespace.
+func RunSandboxInit() {
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Args[2]), &spec); err != nil {
+		sandboxFatal("bad spec: %v", err)
+	}
+
+	// Signals reach the server through its process group; init only has to
+	// survive them (PID 1 ignores signals it has no handler for anyway)
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		sandboxFatal("getwd: %v", err)
+	}
+	if err := setupSandboxFS(spec); err != nil {
+		sandboxFatal("%v", err)
+	}
+	if err := os.Chdir(cwd); err != nil {
+		sandboxFatal("chdir %s: %v", cwd, err)
+	}
+	if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+		sandboxFatal("sethostname: %v", err)
+	}
+	if spec.PrivateNetwork {
+		if err := loopbackUp(); err != nil {
+			sandboxFatal("loopback: %v", err)
+		}
+	}
+
+	attr := &syscall.SysProcAttr{}
+	if spec.UID != nil {
+		attr.Credential = &syscall.Credential{Uid: *spec.UID, Gid: spec.GID, Groups: spec.Groups}
+	}
+	proc, err := os.StartProcess(spec.Path, spec.Args, &os.ProcAttr{
+		Dir:   cwd,
+		Env:   os.Environ(),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Sys:   attr,
+	})
+	if err != nil {
+		sandboxFatal("start %s: %v", spec.Path, err)
+	}
+
+	// Reap everything; leave with the server
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			sandboxFatal("wait: %v", err)
+		}
+		if pid != proc.Pid {
+			continue
+		}
+		if ws.Signaled() {
+			// The agent decodes the signal from our exit code but can't
+			// tell a core dump; leave that in the log
+			if ws.CoreDump() {
+				fmt.Fprintf(os.Stderr, "[sandbox] process killed by %s (core dumped)\n", signalName(ws.Signal()))
+			}
+			os.Exit(128 + int(ws.Signal()))
+		}
+		os.Exit(ws.ExitStatus())
+	}
+}
+
+func sandboxFatal(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "[sandbox] "+format+"\n", args...)
+	os.Exit(127)
+}
+
+// setupSandboxFS builds the new root: a recursive read-only bind of the host
+// root, fresh /proc, /tmp and /dev/shm (and /sys with a private network),
+// writable binds on top, then pivots into it.
+func setupSandboxFS(spec sandboxSpec) error {
+	root := spec.Root
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := syscall.Mount("/", root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind root: %w", err)
+	}
+	if err := remountReadOnly(root); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	for _, dir := range []string{"tmp", "dev/shm"} {
+		target := filepath.Join(root, dir)
+		if _, err := os.Stat(target); err != nil {
+			continue
+		}
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount /%s: %w", dir, err)
+		}
+	}
+
+	if spec.PrivateNetwork {
+		// sysfs shows the network devices of the namespace that mounts it
+		if err := syscall.Mount("sysfs", filepath.Join(root, "sys"), "sysfs", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("mount /sys: %w", err)
+		}
+	}
+
+	// After the tmpfs mounts, so writable paths below /tmp stay visible
+	for _, w := range spec.Writable {
+		target := filepath.Join(root, w)
+		if _, err := os.Stat(target); err != nil {
+			// hidden by a fresh tmpfs
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("mountpoint for %s: %w", w, err)
+			}
+		}
+		if err := syscall.Mount(w, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s read-write: %w", w, err)
+		}
+	}
+
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	// pivot_root(".", ".") stacks the old root on top of the new one, so it
+	// can be detached without needing a (writable) mountpoint for it
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	return os.Chdir("/")
+}
+
+// remountReadOnly makes every mount at or below root read-only, keeping its
+// other flags (a bind remount must repeat them).
+func remountReadOnly(root string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mnt := unescapeMountPath(fields[4])
+		if mnt != root && !strings.HasPrefix(mnt, root+"/") {
+			continue
+		}
+
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		for _, opt := range strings.Split(fields[5], ",") {
+			switch opt {
+			case "nosuid":
+				flags |= syscall.MS_NOSUID
+			case "nodev":
+				flags |= syscall.MS_NODEV
+			case "noexec":
+				flags |= syscall.MS_NOEXEC
+			case "noatime":
+				flags |= syscall.MS_NOATIME
+			case "nodiratime":
+				flags |= syscall.MS_NODIRATIME
+			case "relatime":
+				flags |= syscall.MS_RELATIME
+			}
+		}
+		if err := syscall.Mount("", mnt, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-only: %w", mnt, err)
+		}
+	}
+	return sc.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 etc.) of mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// loopbackUp brings lo up in a fresh network namespace.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(ifr.name[:], "lo")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	ifr.flags |= syscall.IFF_UP | syscall.IFF_RUNNING
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The sandbox re-executes the running binary as its init, so the test
+// binary has to be able to play that part too.
+func TestMain(m *testing.M) {
+	if IsSandboxInit(os.Args) {
+		RunSandboxInit()
+	}
+	os.Exit(m.Run())
+}
+
+func TestSandboxHidesHostPIDs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("sandbox requires root")
+	}
+
+	dir := t.TempDir()
+	m := NewManager(filepath.Join(dir, "run"), "", "")
+	logPath := filepath.Join(dir, "sbx.log")
+
+	// Only shell builtins, so listing /proc forks nothing that could show up
+	// in it. $$ is the shell's PID as seen inside the sandbox.
+	cfg := ServerConfig{
+		Name:    "sbx",
+		Command: "sh",
+		Args:    []string{"-c", `echo "self $$"; for p in /proc/[0-9]*; do echo "${p#/proc/}"; done`},
+		Cwd:     dir,
+		Sandbox: Sandbox{Enabled: true, Writable: []string{dir}},
+	}
+	if _, err := m.Start(cfg, logPath, TriggerAPI); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	st := m.Status(cfg.Name)
+	for st.Running {
+		if time.Now().After(deadline) {
+			t.Fatalf("sandboxed process still running after 10s")
+		}
+		time.Sleep(50 * time.Millisecond)
+		st = m.Status(cfg.Name)
+	}
+	if st.State != StateExited {
+		t.Fatalf("state = %s (%s), want %s", st.State, st.StateReason, StateExited)
+	}
+
+	b, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	self, err := strconv.Atoi(strings.TrimPrefix(lines[0], "self "))
+	if err != nil {
+		t.Fatalf("unexpected first line %q in log:\n%s", lines[0], b)
+	}
+	var pids []int
+	for _, line := range lines[1:] {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			t.Fatalf("unexpected output %q in log:\n%s", line, b)
+		}
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	// The namespace holds the sandbox init (PID 1) and the shell, nothing else
+	if len(pids) != 2 || pids[0] != 1 || pids[1] != self {
+		t.Errorf("sandbox sees PIDs %v, want only [1 %d] from its own namespace", pids, self)
+	}
+}
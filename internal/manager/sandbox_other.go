@@ -0,0 +1,18 @@
+//go:build !linux
+
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func (m *Manager) sandbox(cmd *exec.Cmd, cfg ServerConfig) error {
+	return fmt.Errorf("%s: sandbox requires Linux namespaces", cfg.Name)
+}
+
+func RunSandboxInit() {
+	fmt.Fprintln(os.Stderr, "sandbox requires Linux namespaces")
+	os.Exit(1)
+}
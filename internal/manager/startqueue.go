@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StartQueue limits how many servers may be starting at the same time.
+// A server holds its start slot while it is in the starting state (running
+// pre_start hooks or waiting for its health check), or until Timeout has
+// elapsed. Starts beyond MaxConcurrent wait in the queued state, first come
+// first served.
+type StartQueue struct {
+	MaxConcurrent int           // 0 = unlimited
+	Timeout       time.Duration // 0 = hold the slot until the server leaves starting
+}
+
+// SetStartQueue changes the agent-wide start limit. Raising it lets queued
+// servers start right away.
+func (m *Manager) SetStartQueue(q StartQueue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.startQueue = q
+	m.kickStartQueue()
+}
+
+// startSlotsInUse counts the servers currently holding a start slot.
+// Caller must hold m.mu.
+func (m *Manager) startSlotsInUse() int {
+	n := 0
+	for _, p := range m.procs {
+		if p.state.State != StateStarting {
+			continue
+		}
+		if m.startQueue.Timeout > 0 && time.Since(p.slotSince) >= m.startQueue.Timeout {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// startSlotFree reports whether p may start now rather than queue.
+// Caller must hold m.mu.
+func (m *Manager) startSlotFree() bool {
+	if m.startQueue.MaxConcurrent <= 0 {
+		return true
+	}
+	return len(m.queued) == 0 && m.startSlotsInUse() < m.startQueue.MaxConcurrent
+}
+
+// enqueueStart puts p at the back of the start queue. Caller must hold m.mu.
+func (m *Manager) enqueueStart(p *managedProc, reason string, trigger string) error {
+	if err := p.transition(StateQueued, fmt.Sprintf("%s, waiting for a start slot (%d starting)", reason, m.startSlotsInUse())); err != nil {
+		return err
+	}
+	p.queuedReason = reason
+	p.trigger = trigger
+	m.queued = append(m.queued, p)
+
+	if !m.queueRunning {
+		m.queueRunning = true
+		go m.runStartQueue()
+	}
+	return nil
+}
+
+// dequeueStart drops p from the start queue. Caller must hold m.mu.
+func (m *Manager) dequeueStart(p *managedProc) {
+	for i, q := range m.queued {
+		if q == p {
+			m.queued = append(m.queued[:i], m.queued[i+1:]...)
+			return
+		}
+	}
+}
+
+// queuePosition is p's 1-based place in the start queue, or 0 if it is not
+// queued. Caller must hold m.mu.
+func (m *Manager) queuePosition(p *managedProc) int {
+	for i, q := range m.queued {
+		if q == p {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// kickStartQueue asks the queue runner to look for free slots now instead
+// of at its next tick. Caller must hold m.mu.
+func (m *Manager) kickStartQueue() {
+	if !m.queueRunning {
+		return
+	}
+	select {
+	case m.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// runStartQueue starts queued servers as slots free up. Slots are released
+// by state changes all over the manager and by the timeout, so it polls
+// once a second in addition to being kicked. It exits once the queue is
+// empty.
+func (m *Manager) runStartQueue() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-m.queueWake:
+		}
+
+		m.mu.Lock()
+		m.pumpStartQueue()
+		if len(m.queued) == 0 {
+			m.queueRunning = false
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+	}
+}
+
+// pumpStartQueue launches queued servers while there are free slots.
+// Caller must hold m.mu; it is released while pre_start hooks run.
+func (m *Manager) pumpStartQueue() {
+	for len(m.queued) > 0 {
+		if m.startQueue.MaxConcurrent > 0 && m.startSlotsInUse() >= m.startQueue.MaxConcurrent {
+			return
+		}
+		p := m.queued[0]
+		m.queued = m.queued[1:]
+		if m.procs[p.cfg.Name] != p || p.state.State != StateQueued {
+			continue
+		}
+
+		if err := m.launchNow(p, p.queuedReason, p.trigger); err != nil {
+			if p.trigger == TriggerRestartPolicy && m.procs[p.cfg.Name] == p && !errors.Is(err, errStartCancelled) {
+				m.scheduleRestart(p)
+			}
+		}
+	}
+}
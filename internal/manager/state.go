@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LifecycleState is where a server is in its lifecycle.
+type LifecycleState string
+
+const (
+	StateStopped   LifecycleState = "stopped"    // never started, or stopped on request
+	StateQueued    LifecycleState = "queued"     // waiting for a start slot (see StartQueue)
+	StateStarting  LifecycleState = "starting"   // running pre_start hooks, or spawned and waiting for its health check
+	StateRunning   LifecycleState = "running"    // spawned (and ready, if it has a health check)
+	StateStopping  LifecycleState = "stopping"   // stop requested, waiting for exit
+	StateExited    LifecycleState = "exited"     // exited on its own with code 0
+	StateCrashed   LifecycleState = "crashed"    // exited on its own with an error or signal
+	StateKilled    LifecycleState = "killed"     // did not stop within the grace period, got SIGKILL
+	StateFailed    LifecycleState = "failed"     // could not be started
+	StateBackoff   LifecycleState = "backoff"    // waiting for an automatic restart
+	StateCrashLoop LifecycleState = "crash-loop" // restart policy gave up
+)
+
+// alive reports whether a process exists in this state.
+func (s LifecycleState) alive() bool {
+	return s == StateStarting || s == StateRunning || s == StateStopping
+}
+
+// down lists the states a server can be (re)started from.
+var down = []LifecycleState{StateStopped, StateExited, StateCrashed, StateKilled, StateFailed, StateBackoff, StateCrashLoop}
+
+var transitions = func() map[LifecycleState][]LifecycleState {
+	t := map[LifecycleState][]LifecycleState{
+		StateQueued:   {StateStarting, StateRunning, StateFailed, StateStopped},
+		StateStarting: {StateStarting, StateRunning, StateStopping, StateStopped, StateExited, StateCrashed, StateFailed},
+		StateRunning:  {StateStopping, StateExited, StateCrashed},
+		StateStopping: {StateStopped, StateKilled},
+		StateExited:   {StateBackoff, StateCrashLoop},
+		StateCrashed:  {StateBackoff, StateCrashLoop},
+		StateFailed:   {StateBackoff, StateCrashLoop},
+		StateBackoff:  {StateStopped},
+	}
+	for _, s := range down {
+		t[s] = append(t[s], StateQueued, StateStarting, StateRunning, StateFailed)
+	}
+	return t
+}()
+
+func canTransition(from, to LifecycleState) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves p to a new lifecycle state, recording when and why.
+// Caller must hold m.mu.
+func (p *managedProc) transition(to LifecycleState, reason string) error {
+	from := p.state.State
+	if from == "" {
+		from = StateStopped
+	}
+	if !canTransition(from, to) {
+		return fmt.Errorf("%s: invalid state transition %s -> %s", p.cfg.Name, from, to)
+	}
+
+	p.state.State = to
+	p.state.StateSince = time.Now()
+	p.state.StateReason = reason
+	p.state.Running = to.alive()
+	return nil
+}
+
+// signalName renders a signal as e.g. "SIGTERM".
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGBUS:
+		return "SIGBUS"
+	case syscall.SIGFPE:
+		return "SIGFPE"
+	case syscall.SIGILL:
+		return "SIGILL"
+	case syscall.SIGUSR1:
+		return "SIGUSR1"
+	case syscall.SIGUSR2:
+		return "SIGUSR2"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	default:
+		return "signal " + strings.ToUpper(sig.String())
+	}
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+var allStates = []LifecycleState{
+	StateStopped, StateQueued, StateStarting, StateRunning, StateStopping, StateExited,
+	StateCrashed, StateKilled, StateFailed, StateBackoff, StateCrashLoop,
+}
+
+// TestCanTransition pins the whole transition table: widening it has to
+// change this test too.
+func TestCanTransition(t *testing.T) {
+	// Every state a server is down in can be started from
+	start := []LifecycleState{StateQueued, StateStarting, StateRunning, StateFailed}
+	with := func(extra ...LifecycleState) []LifecycleState {
+		return append(append([]LifecycleState{}, start...), extra...)
+	}
+
+	allowed := map[LifecycleState][]LifecycleState{
+		StateStopped:   with(),
+		StateQueued:    {StateStarting, StateRunning, StateFailed, StateStopped},
+		StateStarting:  {StateStarting, StateRunning, StateStopping, StateStopped, StateExited, StateCrashed, StateFailed},
+		StateRunning:   {StateStopping, StateExited, StateCrashed},
+		StateStopping:  {StateStopped, StateKilled},
+		StateExited:    with(StateBackoff, StateCrashLoop),
+		StateCrashed:   with(StateBackoff, StateCrashLoop),
+		StateKilled:    with(),
+		StateFailed:    with(StateBackoff, StateCrashLoop),
+		StateBackoff:   with(StateStopped),
+		StateCrashLoop: with(),
+	}
+
+	for _, from := range allStates {
+		want := map[LifecycleState]bool{}
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range allStates {
+			if got := canTransition(from, to); got != want[to] {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want[to])
+			}
+		}
+	}
+
+	// No state outside the list above may appear in the table
+	for from, tos := range transitions {
+		for _, to := range append([]LifecycleState{from}, tos...) {
+			known := false
+			for _, s := range allStates {
+				known = known || s == to
+			}
+			if !known {
+				t.Errorf("transition table mentions unlisted state %q", to)
+			}
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		from, to    LifecycleState
+		wantErr     bool
+		wantRunning bool
+	}{
+		{"", StateStarting, false, true}, // a new server counts as stopped
+		{"", StateStopping, true, false}, // so it cannot be stopping
+		{StateStarting, StateRunning, false, true},
+		{StateRunning, StateStopping, false, true},
+		{StateStopping, StateKilled, false, false},
+		{StateCrashed, StateBackoff, false, false},
+		{StateRunning, StateQueued, true, true},
+	}
+	for _, tt := range tests {
+		p := &managedProc{cfg: ServerConfig{Name: "a"}}
+		p.state.State = tt.from
+		p.state.Running = tt.from.alive()
+
+		err := p.transition(tt.to, "because")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q -> %s: error %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if p.state.State != tt.from {
+				t.Errorf("%q -> %s: failed transition changed the state to %s", tt.from, tt.to, p.state.State)
+			}
+			continue
+		}
+		if p.state.State != tt.to || p.state.StateReason != "because" || p.state.StateSince.IsZero() {
+			t.Errorf("%q -> %s: state %s reason %q since %v", tt.from, tt.to, p.state.State, p.state.StateReason, p.state.StateSince)
+		}
+		if p.state.Running != tt.wantRunning {
+			t.Errorf("%q -> %s: running = %v, want %v", tt.from, tt.to, p.state.Running, tt.wantRunning)
+		}
+	}
+}
+
+func TestAliveStates(t *testing.T) {
+	var got []string
+	for _, s := range allStates {
+		if s.alive() {
+			got = append(got, string(s))
+		}
+	}
+	sort.Strings(got)
+	if want := "running,starting,stopping"; strings.Join(got, ",") != want {
+		t.Errorf("alive states = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultStopWait = 10 * time.Second
+
+// stopSteps returns the escalation chain for cfg, ending in SIGKILL. Adopted
+// processes have no stdin pipe, so their stdin steps become SIGTERM.
+func stopSteps(cfg StopConfig, noStdin bool) []StopStep {
+	steps := cfg.Steps
+	if len(steps) == 0 {
+		// Single-action form: one graceful step, then SIGKILL
+		steps = []StopStep{{
+			Type:         cfg.Type,
+			Signal:       cfg.Signal,
+			StdinCommand: cfg.StdinCommand,
+			Wait:         cfg.GracePeriod,
+			ExitEarly:    true,
+		}}
+		if steps[0].Wait == 0 {
+			steps[0].Wait = defaultStopWait
+		}
+	}
+
+	out := make([]StopStep, 0, len(steps)+1)
+	for _, st := range steps {
+		if st.Type == StopStdin && st.StdinCommand == "" {
+			st.StdinCommand = "stop"
+		}
+		if st.Type == StopStdin && !strings.HasSuffix(st.StdinCommand, "\n") {
+			st.StdinCommand += "\n"
+		}
+		if st.Type == StopStdin && noStdin {
+			st.Type = StopSignal
+			st.Signal = syscall.SIGTERM
+			st.StdinCommand = ""
+		}
+		if st.Type != StopStdin && st.Signal == 0 {
+			st.Type = StopSignal
+			st.Signal = syscall.SIGTERM
+		}
+		out = append(out, st)
+	}
+
+	if last := out[len(out)-1]; last.Type != StopSignal || last.Signal != syscall.SIGKILL {
+		out = append(out, StopStep{Type: StopSignal, Signal: syscall.SIGKILL, Wait: 250 * time.Millisecond, ExitEarly: true})
+	}
+	return out
+}
+
+func (st StopStep) String() string {
+	if st.Type == StopStdin {
+		return fmt.Sprintf("stdin %q", strings.TrimSpace(st.StdinCommand))
+	}
+	return signalName(st.Signal)
+}
+
+// runStopSteps walks the chain until the process is gone. The step in flight
+// when the process exits is recorded as the one that stopped it.
+func (m *Manager) runStopSteps(p *managedProc, steps []StopStep, pid int) {
+	var waited time.Duration
+
+	for i, st := range steps {
+		if !m.isCurrentRun(p, pid) {
+			return
+		}
+
+		label := fmt.Sprintf("step %d/%d: %s", i+1, len(steps), st)
+		var reason string
+		killed := st.Type == StopSignal && st.Signal == syscall.SIGKILL
+		switch {
+		case st.Type == StopStdin:
+			reason = fmt.Sprintf("stopped via stdin command %q", strings.TrimSpace(st.StdinCommand))
+		case killed && i > 0:
+			reason = fmt.Sprintf("killed with SIGKILL after %s", waited)
+		default:
+			reason = fmt.Sprintf("stopped via %s", signalName(st.Signal))
+		}
+		// Record before acting so a fast exit is attributed to this step
+		m.setStopReason(p, label, reason, killed)
+
+		if st.Type == StopStdin {
+			_ = m.writeStdin(p, st.StdinCommand)
+		} else {
+			// kill process group: negative PID
+			_ = syscall.Kill(-pid, st.Signal)
+			m.signalDetached(p, st.Signal)
+		}
+
+		deadline := time.Now().Add(st.Wait)
+		for time.Now().Before(deadline) {
+			if st.ExitEarly && !m.isCurrentRun(p, pid) {
+				return
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+		waited += st.Wait
+	}
+}
+
+// isCurrentRun reports whether p is still running as pid.
+func (m *Manager) isCurrentRun(p *managedProc, pid int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return p.state.Running && p.state.PID == pid
+}
+
+func (m *Manager) setStopReason(p *managedProc, step string, reason string, killed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if step != "" {
+		p.stopStep = step
+	}
+	p.stopReason = reason
+	p.killed = killed
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStopSteps(t *testing.T) {
+	kill := StopStep{Type: StopSignal, Signal: syscall.SIGKILL, Wait: 250 * time.Millisecond, ExitEarly: true}
+
+	tests := []struct {
+		name    string
+		cfg     StopConfig
+		noStdin bool
+		want    []StopStep
+	}{
+		{
+			name: "legacy signal",
+			cfg:  StopConfig{Type: StopSignal, Signal: syscall.SIGINT, GracePeriod: 15 * time.Second},
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGINT, Wait: 15 * time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "legacy stdin gets a newline",
+			cfg:  StopConfig{Type: StopStdin, StdinCommand: "save-all", GracePeriod: 30 * time.Second},
+			want: []StopStep{
+				{Type: StopStdin, StdinCommand: "save-all\n", Wait: 30 * time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "legacy stdin defaults to stop",
+			cfg:  StopConfig{Type: StopStdin},
+			want: []StopStep{
+				{Type: StopStdin, StdinCommand: "stop\n", Wait: defaultStopWait, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "empty config is SIGTERM with the default wait",
+			cfg:  StopConfig{},
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: defaultStopWait, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "explicit chain",
+			cfg: StopConfig{Steps: []StopStep{
+				{Type: StopStdin, StdinCommand: "save-all\n", Wait: 5 * time.Second},
+				{Type: StopStdin, StdinCommand: "stop", Wait: 30 * time.Second, ExitEarly: true},
+				{Type: StopSignal, Wait: 10 * time.Second, ExitEarly: true},
+			}},
+			want: []StopStep{
+				{Type: StopStdin, StdinCommand: "save-all\n", Wait: 5 * time.Second},
+				{Type: StopStdin, StdinCommand: "stop\n", Wait: 30 * time.Second, ExitEarly: true},
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: 10 * time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "chain ending in SIGKILL gets no second one",
+			cfg: StopConfig{Steps: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: 10 * time.Second, ExitEarly: true},
+				{Type: StopSignal, Signal: syscall.SIGKILL, Wait: 2 * time.Second},
+			}},
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: 10 * time.Second, ExitEarly: true},
+				{Type: StopSignal, Signal: syscall.SIGKILL, Wait: 2 * time.Second},
+			},
+		},
+		{
+			name: "SIGKILL earlier in the chain still gets a final one",
+			cfg: StopConfig{Steps: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGKILL, Wait: time.Second},
+				{Type: StopStdin, StdinCommand: "stop\n", Wait: time.Second},
+			}},
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGKILL, Wait: time.Second},
+				{Type: StopStdin, StdinCommand: "stop\n", Wait: time.Second},
+				kill,
+			},
+		},
+		{
+			name:    "legacy stdin without stdin becomes SIGTERM",
+			cfg:     StopConfig{Type: StopStdin, StdinCommand: "stop", GracePeriod: 20 * time.Second},
+			noStdin: true,
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: 20 * time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name: "chain without stdin keeps its signal steps",
+			cfg: StopConfig{Steps: []StopStep{
+				{Type: StopStdin, StdinCommand: "save-all", Wait: 5 * time.Second},
+				{Type: StopSignal, Signal: syscall.SIGINT, Wait: 10 * time.Second, ExitEarly: true},
+			}},
+			noStdin: true,
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGTERM, Wait: 5 * time.Second},
+				{Type: StopSignal, Signal: syscall.SIGINT, Wait: 10 * time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+		{
+			name:    "signal config is unaffected without stdin",
+			cfg:     StopConfig{Type: StopSignal, Signal: syscall.SIGQUIT, GracePeriod: time.Second},
+			noStdin: true,
+			want: []StopStep{
+				{Type: StopSignal, Signal: syscall.SIGQUIT, Wait: time.Second, ExitEarly: true},
+				kill,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stopSteps(tt.cfg, tt.noStdin)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stopSteps =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopStepsDoesNotModifyConfig(t *testing.T) {
+	cfg := StopConfig{Steps: []StopStep{{Type: StopStdin, StdinCommand: "stop", Wait: time.Second}}}
+	stopSteps(cfg, true)
+	if st := cfg.Steps[0]; st.Type != StopStdin || st.StdinCommand != "stop" {
+		t.Errorf("config step changed to %+v", st)
+	}
+}
@@ -1,8 +1,8 @@
 package manager
 
 import (
-	"bufio"
 	"context"
+	"os"
 	"os/exec"
 	"sync"
 	"syscall"
@@ -21,6 +21,39 @@ type StopConfig struct {
 	Signal       syscall.Signal // used if Type=signal
 	StdinCommand string         // used if Type=stdin
 	GracePeriod  time.Duration  // how long before SIGKILL
+
+	// Steps, when set, replace Type/Signal/StdinCommand/GracePeriod with an
+	// ordered escalation chain. SIGKILL is always the implicit last resort.
+	Steps []StopStep
+}
+
+// StopStep is one action of a stop chain followed by a wait for the process
+// to exit.
+type StopStep struct {
+	Type         StopType
+	Signal       syscall.Signal // used if Type=signal
+	StdinCommand string         // used if Type=stdin
+	Wait         time.Duration
+
+	// ExitEarly ends the wait as soon as the process is gone; otherwise the
+	// full wait elapses (e.g. to give child processes time to flush).
+	ExitEarly bool
+}
+
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+type RestartPolicy struct {
+	Mode        RestartMode
+	Backoff     time.Duration // delay before the first restart, doubled per restart in Window
+	MaxBackoff  time.Duration // upper bound for the doubled delay
+	MaxRestarts int           // restarts allowed within Window before crash-loop (0 = unlimited)
+	Window      time.Duration // sliding window MaxRestarts is counted over
 }
 
 type ServerConfig struct {
@@ -30,34 +63,156 @@ type ServerConfig struct {
 	Cwd     string
 	Env     []string
 	Stop    StopConfig
+	Restart RestartPolicy
+	Logs    LogRotation
+
+	Resources Resources
+	Health    HealthCheck
+	Hooks     Hooks
+	RunAs     RunAs
+	Sandbox   Sandbox
+	Pty       Pty
 }
 
 type ServerState struct {
-	Name      string
-	Running   bool
+	Name string
+
+	State       LifecycleState
+	StateSince  time.Time
+	StateReason string // why the last transition happened
+
+	Running   bool // process exists (starting, running or stopping)
 	PID       int
+	RunID     string // identifies the current (or last) run in the run history
 	StartedAt time.Time
 	ExitedAt  time.Time
 	ExitCode  int
 	LastError string
+
+	// How the last run ended (see recordExitDiagnostics)
+	ExitSignal  string   // e.g. "SIGSEGV" if a signal ended it
+	CoreDumped  bool     // the signal dumped core
+	OOMKilled   bool     // the kernel OOM killer was involved
+	ExitLogTail []string // last log lines if it crashed, was killed or failed to start
+	StoppedBy   string   // stop step that ended the last run, e.g. `step 2/4: stdin "stop"`
+
+	Adopted bool // re-attached after an agent restart (no stdin, exit code unknown)
+
+	Restarts      int       // automatic restarts since the last manual start
+	NextRestartAt time.Time // set while an automatic restart is pending
+
+	QueuePosition int // 1-based place in the start queue while queued
+
+	LogSegments []LogSegment // rotated log segments, newest first
+
+	Cgroup string // cgroup v2 directory when template resources apply
+
+	Processes []ProcessInfo // the run's process tree while running (see processTracker)
+
+	Metrics *ProcessMetrics // latest resource sample while running
+
+	Health          HealthStatus // empty when the template has no health check
+	HealthCheckedAt time.Time
+	HealthMessage   string // last probe failure
 }
 
 type managedProc struct {
-	cfg   ServerConfig
-	cmd   *exec.Cmd
-	state ServerState
+	cfg     ServerConfig
+	cmd     *exec.Cmd
+	state   ServerState
+	logPath string
+
+	// procStartTime identifies the process across PID reuse (from /proc)
+	procStartTime uint64
+
+	// OOM kill counters when the run started
+	oomBaseline oomBaseline
+
+	// tree follows the current run's processes; leftovers says what ending
+	// the run did about processes its main process left behind
+	tree      *processTracker
+	leftovers string
+
+	// stdin is the write end of the stdin pipe, or the pty master; stdinMu
+	// serializes writes to it
+	stdin   *os.File
+	stdinMu sync.Mutex
+	cancel  context.CancelFunc
+
+	// done is closed when the current run of the process ends
+	done chan struct{}
+
+	metrics metricsRing
+
+	// Start queue bookkeeping (see StartQueue)
+	slotSince    time.Time
+	queuedReason string
+
+	// What started the current run and what asked it to stop (run history)
+	trigger     string
+	stopTrigger string
 
-	stdin  *bufio.Writer
-	cancel context.CancelFunc
+	// Stop was called while pre_start hooks ran; the start is called off
+	startCancelled bool
+
+	// How the current stop ended the process (see Stop)
+	stopReason string
+	stopStep   string
+	killed     bool
+
+	// runsEnded counts the runs recorded in history, to tell whether a
+	// pending record still describes the current state
+	runsEnded int
+
+	// Restart bookkeeping
+	restartTimes []time.Time
+	restartTimer *time.Timer
 }
 
 type Manager struct {
 	mu    sync.Mutex
 	procs map[string]*managedProc
+
+	// runDir holds per-process run records used to re-adopt processes after
+	// an agent restart. Empty disables persistence.
+	runDir string
+
+	// historyDir holds one run history file per server. Empty disables it.
+	historyDir string
+
+	// cgroupParent is the delegated cgroup v2 directory servers with
+	// resources are placed under. Empty disables resource limits.
+	cgroupParent string
+
+	// Agent-wide limit on concurrent starts, and the servers waiting for a slot
+	startQueue   StartQueue
+	queued       []*managedProc
+	queueRunning bool
+	queueWake    chan struct{}
+
+	// cordoned turns off the manager's own restarts (see SetCordoned)
+	cordoned bool
+
+	// netProbe runs tcp and http health checks (see SetNetworkProbe)
+	netProbe NetworkProbe
+
+	// Run history waiting to be written, so exits don't wait on the disk
+	// (see recordRun). historyMu serializes access to the history files and
+	// is taken after mu, never before; historyRuns counts the runs in each
+	// file once known and is guarded by it.
+	historyPending []pendingRun
+	historyWriting bool
+	historyMu      sync.Mutex
+	historyRuns    map[string]int
 }
 
-func NewManager() *Manager {
+func NewManager(runDir string, historyDir string, cgroupParent string) *Manager {
 	return &Manager{
-		procs: map[string]*managedProc{},
+		procs:        map[string]*managedProc{},
+		runDir:       runDir,
+		historyDir:   historyDir,
+		cgroupParent: cgroupParent,
+		queueWake:    make(chan struct{}, 1),
+		historyRuns:  map[string]int{},
 	}
 }
@@ -1,17 +1,63 @@
 package protocol
 
+import "time"
+
 // Request types (m.Type)
 const (
 	CmdStart  = "start"
 	CmdStop   = "stop"
 	CmdStatus = "status"
 	CmdList   = "list"
+
+	CmdRestart        = "restart"
+	CmdRestartRolling = "restart.rolling"
 )
 
 type RegisterPayload struct {
 	Servers []string `json:"servers"`
+
+	// Outcomes of the instance autostart since the agent booted
+	Autostart []AutostartResult `json:"autostart,omitempty"`
+
+	// Set while the agent is cordoned (see CmdCordon)
+	Cordoned     bool   `json:"cordoned,omitempty"`
+	CordonReason string `json:"cordon_reason,omitempty"`
+}
+
+type AutostartResult struct {
+	Instance string    `json:"instance"`
+	Outcome  string    `json:"outcome"` // pending, started, already-running, disabled, cordoned or failed
+	State    string    `json:"state,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at,omitzero"`
 }
 
 type ServerTarget struct {
 	Server string `json:"server"`
 }
+
+// RollingRestartRequest restarts instances one at a time, waiting for each to
+// be running (and healthy, if it has a health check) before the next.
+type RollingRestartRequest struct {
+	Servers []string `json:"servers,omitempty"` // empty = all running instances
+
+	// Per instance wait for readiness (default 300)
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// Keep going after an instance fails to come back
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+}
+
+type RollingRestartResult struct {
+	Server     string `json:"server"`
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"` // not attempted after an earlier failure
+	State      string `json:"state,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+type RollingRestartResponse struct {
+	OK      bool                   `json:"ok"`
+	Results []RollingRestartResult `json:"results"`
+}
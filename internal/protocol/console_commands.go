@@ -0,0 +1,18 @@
+package protocol
+
+const (
+	// CmdConsoleSend writes a command to an instance's stdin and returns the
+	// log lines the instance produced during the following WaitMS.
+	CmdConsoleSend = "console.send"
+)
+
+type ConsoleRequest struct {
+	Server  string `json:"server"`
+	Command string `json:"command"`
+	WaitMS  int    `json:"wait_ms,omitempty"`
+}
+
+type ConsoleResponse struct {
+	Server string   `json:"server"`
+	Lines  []string `json:"lines"`
+}
@@ -0,0 +1,26 @@
+package protocol
+
+const (
+	// Group commands act on every instance with the given group, in
+	// dependency order
+	CmdGroupStart = "group.start"
+	CmdGroupStop  = "group.stop"
+)
+
+type GroupRequest struct {
+	Group string `json:"group"`
+}
+
+type GroupResult struct {
+	Instance string `json:"instance"`
+	OK       bool   `json:"ok"`
+	Skipped  bool   `json:"skipped,omitempty"` // not attempted after an earlier failure
+	State    string `json:"state,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type GroupResponse struct {
+	OK      bool          `json:"ok"`
+	Group   string        `json:"group"`
+	Results []GroupResult `json:"results"` // in the order the instances were handled
+}
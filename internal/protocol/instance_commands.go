@@ -1,26 +1,67 @@
 package protocol
 
+import "time"
+
 const (
 	// Instance management commands (agent-side)
-	CmdInstancesList   = "instances.list"
-	CmdInstancesCreate = "instances.create"
-	CmdInstancesDelete = "instances.delete"
+	CmdInstancesList    = "instances.list"
+	CmdInstancesCreate  = "instances.create"
+	CmdInstancesDelete  = "instances.delete"
+	CmdInstancesHistory = "instances.history"
 )
 
 type InstanceSummary struct {
-	Name     string            `json:"name"`
-	Template string            `json:"template"`
-	Enabled  bool              `json:"enabled"`
-	Params   map[string]string `json:"params,omitempty"`
-	Running  bool              `json:"running"`
-	PID      int               `json:"pid,omitempty"`
+	Name      string            `json:"name"`
+	Template  string            `json:"template"`
+	Enabled   bool              `json:"enabled"`
+	Autostart bool              `json:"autostart,omitempty"`
+	DependsOn []string          `json:"depends_on,omitempty"`
+	Group     string            `json:"group,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+
+	// Lifecycle state: stopped, queued, starting, running, stopping, exited,
+	// crashed, killed, failed, backoff or crash-loop
+	State       string    `json:"state"`
+	StateSince  time.Time `json:"state_since,omitempty"`
+	StateReason string    `json:"state_reason,omitempty"`
+	StoppedBy   string    `json:"stopped_by,omitempty"` // stop step that ended the last run
+
+	Running bool   `json:"running"`
+	PID     int    `json:"pid,omitempty"`
+	RunID   string `json:"run_id,omitempty"` // current or last run, see instances.history
+	Adopted bool   `json:"adopted,omitempty"`
+	Health  string `json:"health,omitempty"` // "starting", "ready" or "unhealthy"
+
+	Restarts      int `json:"restarts,omitempty"`
+	QueuePosition int `json:"queue_position,omitempty"` // place in the agent's start queue while queued
+
+	LogPath     string       `json:"log_path,omitempty"`
+	LogSegments []LogSegment `json:"log_segments,omitempty"`
+}
+
+type LogSegment struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	Compressed bool      `json:"compressed"`
 }
 
 type CreateInstanceRequest struct {
-	Name     string            `json:"name"`
-	Template string            `json:"template"`
-	Enabled  bool              `json:"enabled"`
-	Params   map[string]string `json:"params,omitempty"`
+	Name      string            `json:"name"`
+	Template  string            `json:"template"`
+	Enabled   bool              `json:"enabled"`
+	Params    map[string]string `json:"params,omitempty"`
+	Schedules []ScheduleSpec    `json:"schedules,omitempty"`
+	Autostart *AutostartSpec    `json:"autostart,omitempty"`
+	DependsOn []string          `json:"depends_on,omitempty"` // instances that must be ready first
+	Group     string            `json:"group,omitempty"`
+}
+
+// AutostartSpec makes the agent start the instance when it boots.
+type AutostartSpec struct {
+	Enabled  bool   `json:"enabled"`
+	Delay    string `json:"delay,omitempty"`    // e.g. "30s"
+	Priority int    `json:"priority,omitempty"` // higher starts first
 }
 
 type DeleteInstanceRequest struct {
@@ -28,3 +69,37 @@ type DeleteInstanceRequest struct {
 	Force      bool   `json:"force"`       // stop if running
 	DeleteData bool   `json:"delete_data"` // remove instance directory
 }
+
+type InstanceHistoryRequest struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit,omitempty"` // newest runs to return (default 20)
+}
+
+// RunHistoryEntry is one finished run of an instance (or a failed start).
+type RunHistoryEntry struct {
+	RunID      string    `json:"run_id"`
+	Trigger    string    `json:"trigger"` // api, schedule:<name>, restart-policy, health-check, ...
+	PID        int       `json:"pid,omitempty"`
+	Adopted    bool      `json:"adopted,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	DurationMS int64     `json:"duration_ms"`
+
+	State    string `json:"state"` // stopped, killed, exited, crashed or failed
+	Reason   string `json:"reason,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+
+	CoreDumped bool `json:"core_dumped,omitempty"`
+	OOMKilled  bool `json:"oom_killed,omitempty"`
+
+	StopTrigger string `json:"stop_trigger,omitempty"`
+	StoppedBy   string `json:"stopped_by,omitempty"`
+
+	LogTail []string `json:"log_tail,omitempty"` // only for crashed, killed and failed runs
+}
+
+type InstanceHistoryResponse struct {
+	Name string            `json:"name"`
+	Runs []RunHistoryEntry `json:"runs"`
+}
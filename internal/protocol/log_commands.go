@@ -0,0 +1,27 @@
+package protocol
+
+const (
+	// CmdLogs returns the tail of an instance log. With Follow set, the agent
+	// keeps sending KindStream messages carrying the request ID until
+	// CmdLogsUnsubscribe (or the connection) ends the subscription.
+	CmdLogs            = "logs"
+	CmdLogsUnsubscribe = "logs.unsubscribe"
+)
+
+type LogsRequest struct {
+	Server string `json:"server"`
+	Tail   int    `json:"tail"`
+	Follow bool   `json:"follow"`
+}
+
+type LogsResponse struct {
+	Server string   `json:"server"`
+	Lines  []string `json:"lines"`
+}
+
+// LogChunk is the payload of KindStream messages for log subscriptions.
+type LogChunk struct {
+	Data  string `json:"data,omitempty"`
+	EOF   bool   `json:"eof,omitempty"` // stream ended (see Error)
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,40 @@
+package protocol
+
+import "time"
+
+const (
+	// Maintenance commands act on the whole agent: a cordoned agent refuses
+	// starts and creates; a drain cordons it and stops every instance
+	CmdCordon      = "agent.cordon"
+	CmdUncordon    = "agent.uncordon"
+	CmdDrain       = "agent.drain"
+	CmdMaintenance = "agent.maintenance"
+)
+
+type CordonRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type MaintenanceStatus struct {
+	Cordoned bool         `json:"cordoned"`
+	Reason   string       `json:"reason,omitempty"`
+	Since    time.Time    `json:"since,omitzero"`
+	Drain    *DrainStatus `json:"drain,omitempty"` // the last drain, if any
+}
+
+type DrainStatus struct {
+	Done       bool          `json:"done"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at,omitzero"`
+	Stopped    int           `json:"stopped"`
+	Failed     int           `json:"failed"`
+	Remaining  int           `json:"remaining"`
+	Results    []DrainResult `json:"results"` // in stop order
+}
+
+type DrainResult struct {
+	Instance string `json:"instance"`
+	Outcome  string `json:"outcome"` // pending, stopping, stopped or failed
+	State    string `json:"state,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
@@ -13,6 +13,7 @@ const (
 	KindRequest   Kind = "request"
 	KindResponse  Kind = "response"
 	KindHeartbeat Kind = "heartbeat"
+	KindStream    Kind = "stream" // unsolicited agent -> server data for an earlier request
 )
 
 type Message struct {
@@ -25,6 +26,11 @@ type Message struct {
 	TS      time.Time       `json:"ts,omitempty"`
 }
 
+// UnsubscribeRequest ends a stream started by an earlier request.
+type UnsubscribeRequest struct {
+	ID string `json:"id"` // ID of the request that started the stream
+}
+
 func NewRegister(agentID string, payload any) (Message, error) {
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -75,6 +81,20 @@ func NewResponse(agentID, id string, payload any, respErr error) (Message, error
 	return msg, nil
 }
 
+func NewStream(agentID, id string, payload any) (Message, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Kind:    KindStream,
+		ID:      id,
+		AgentID: agentID,
+		Payload: b,
+		TS:      time.Now().UTC(),
+	}, nil
+}
+
 func (m Message) ValidateBasic() error {
 	if m.Kind == "" {
 		return fmt.Errorf("missing kind")
@@ -101,6 +121,13 @@ func (m Message) ValidateBasic() error {
 		if m.AgentID == "" {
 			return fmt.Errorf("response missing agent_id")
 		}
+	case KindStream:
+		if m.ID == "" {
+			return fmt.Errorf("stream missing id")
+		}
+		if m.AgentID == "" {
+			return fmt.Errorf("stream missing agent_id")
+		}
 	case KindHeartbeat:
 		if m.AgentID == "" {
 			return fmt.Errorf("heartbeat missing agent_id")
@@ -0,0 +1,27 @@
+package protocol
+
+import "time"
+
+const (
+	// CmdMetrics returns the latest resource sample and recent history of an
+	// instance's process tree. Payload: ServerTarget.
+	CmdMetrics = "metrics"
+)
+
+type MetricsSample struct {
+	TS            time.Time `json:"ts"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	RSSBytes      int64     `json:"rss_bytes"`
+	Threads       int       `json:"threads"`
+	OpenFDs       int       `json:"open_fds"`
+	ReadBytes     uint64    `json:"read_bytes"`
+	WriteBytes    uint64    `json:"write_bytes"`
+	Processes     int       `json:"processes"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+type MetricsResponse struct {
+	Server  string          `json:"server"`
+	Current *MetricsSample  `json:"current,omitempty"`
+	Samples []MetricsSample `json:"samples"`
+}
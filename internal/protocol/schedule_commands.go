@@ -0,0 +1,41 @@
+package protocol
+
+import "time"
+
+const (
+	CmdSchedulesList = "schedules.list"
+)
+
+// ScheduleSpec configures a scheduled task when creating an instance.
+type ScheduleSpec struct {
+	Name    string `json:"name,omitempty"`
+	Cron    string `json:"cron"`              // 5-field cron expression or @daily etc.
+	Action  string `json:"action"`            // start, stop, restart, stdin or hook
+	Command string `json:"command,omitempty"` // stdin text or hook script
+	Timeout string `json:"timeout,omitempty"` // hook timeout, e.g. "10m"
+}
+
+// SchedulesRequest lists the schedules of one instance, or all when empty.
+type SchedulesRequest struct {
+	Instance string `json:"instance,omitempty"`
+}
+
+type ScheduleStatus struct {
+	Instance string `json:"instance"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Action   string `json:"action"`
+	Command  string `json:"command,omitempty"`
+
+	NextRun time.Time `json:"next_run,omitzero"`
+	Running bool      `json:"running,omitempty"`
+
+	LastRun        time.Time `json:"last_run,omitzero"`
+	LastDurationMS int64     `json:"last_duration_ms,omitempty"`
+	LastResult     string    `json:"last_result,omitempty"` // "ok", "error" or "skipped"
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+type SchedulesResponse struct {
+	Schedules []ScheduleStatus `json:"schedules"`
+}
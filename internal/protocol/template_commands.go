@@ -0,0 +1,38 @@
+package protocol
+
+const (
+	CmdTemplatesList     = "templates.list"
+	CmdTemplatesDescribe = "templates.describe"
+)
+
+type TemplateRequest struct {
+	Template string `json:"template"`
+}
+
+// TemplateParam is one param a template accepts.
+type TemplateParam struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Values      []string `json:"values,omitempty"` // enum
+	Pattern     string   `json:"pattern,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+type TemplateInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Params      []TemplateParam `json:"params"` // empty: the template declares none and accepts any
+
+	// Set by templates.describe only
+	Command   string   `json:"command,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	Cwd       string   `json:"cwd,omitempty"`
+	Env       []string `json:"env,omitempty"`
+	Instances []string `json:"instances,omitempty"` // instances created from it
+}
+
+type TemplatesResponse struct {
+	Templates []TemplateInfo `json:"templates"` // sorted by name
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func bits(vals ...int) uint64 {
+	var b uint64
+	for _, v := range vals {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func span(lo, hi, step int) uint64 {
+	var b uint64
+	for v := lo; v <= hi; v += step {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr                          string
+		minute, hour, dom, month, dow uint64
+		domStar, dowStar              bool
+	}{
+		{"* * * * *", span(0, 59, 1), span(0, 23, 1), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"0 4 * * *", bits(0), bits(4), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"0,15,45 1-3 * * *", bits(0, 15, 45), bits(1, 2, 3), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"*/15 */6 * * *", bits(0, 15, 30, 45), bits(0, 6, 12, 18), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"0-30/10 0 * * *", bits(0, 10, 20, 30), bits(0), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		// A single value with a step runs to the end of the field
+		{"50/5 0 * * *", bits(50, 55), bits(0), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"0 0 1,15 jan-mar *", bits(0), bits(0), bits(1, 15), bits(1, 2, 3), span(0, 7, 1), false, true},
+		{"0 0 * JUN,Dec MON-fri", bits(0), bits(0), span(1, 31, 1), bits(6, 12), bits(1, 2, 3, 4, 5), true, false},
+		{"0 0 ? * sun", bits(0), bits(0), span(1, 31, 1), span(1, 12, 1), bits(0), true, false},
+		// 7 is Sunday as well
+		{"0 0 * * 7", bits(0), bits(0), span(1, 31, 1), span(1, 12, 1), bits(0, 7), true, false},
+		{"0 0 13 * fri", bits(0), bits(0), bits(13), span(1, 12, 1), bits(5), false, false},
+		{"@daily", bits(0), bits(0), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+		{"@Weekly", bits(0), bits(0), span(1, 31, 1), span(1, 12, 1), bits(0), true, false},
+		{"@yearly", bits(0), bits(0), bits(1), bits(1), span(0, 7, 1), false, true},
+		{"  0 4 * * *  ", bits(0), bits(4), span(1, 31, 1), span(1, 12, 1), span(0, 7, 1), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if s.minute != tt.minute || s.hour != tt.hour || s.dom != tt.dom || s.month != tt.month || s.dow != tt.dow {
+				t.Errorf("Parse(%q) = minute %b hour %b dom %b month %b dow %b, want %b %b %b %b %b",
+					tt.expr, s.minute, s.hour, s.dom, s.month, s.dow, tt.minute, tt.hour, tt.dom, tt.month, tt.dow)
+			}
+			if s.domStar != tt.domStar || s.dowStar != tt.dowStar {
+				t.Errorf("Parse(%q) domStar/dowStar = %v/%v, want %v/%v", tt.expr, s.domStar, s.dowStar, tt.domStar, tt.dowStar)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/-1 * * * *",
+		"*/x * * * *",
+		"1- * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"* * * foo *",
+		"* * * * monday",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("bad time %q: %v", s, err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string // empty for no match
+	}{
+		{"same hour", "30 * * * *", "2026-10-17 10:05", "2026-10-17 10:30"},
+		{"strictly after", "30 * * * *", "2026-10-17 10:30", "2026-10-17 11:30"},
+		{"hour rollover", "0 4 * * *", "2026-10-17 05:00", "2026-10-18 04:00"},
+		{"month rollover", "0 0 1 * *", "2026-01-31 12:00", "2026-02-01 00:00"},
+		{"year rollover", "0 0 1 1 *", "2026-06-01 00:00", "2027-01-01 00:00"},
+		{"day 31 skips short months", "0 0 31 * *", "2026-04-01 00:00", "2026-05-31 00:00"},
+		{"minute step wraps into next hour", "*/15 * * * *", "2026-10-17 10:50", "2026-10-17 11:00"},
+		{"hour step wraps into next day", "0 */10 * * *", "2026-10-17 21:00", "2026-10-18 00:00"},
+		{"step wraps into next year", "0 0 1 */5 *", "2026-11-02 00:00", "2027-01-01 00:00"},
+		{"feb 29 waits for a leap year", "0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"feb 30 never matches", "0 0 30 2 *", "2026-01-01 00:00", ""},
+		{"day of month only", "0 0 13 * *", "2026-10-17 00:00", "2026-11-13 00:00"},
+		{"day of week only", "0 0 * * fri", "2026-10-17 00:00", "2026-10-23 00:00"},
+		{"question mark is a wildcard", "0 0 ? * fri", "2026-10-17 00:00", "2026-10-23 00:00"},
+		{"both day fields, day of week comes first", "0 0 13 * fri", "2026-10-17 00:00", "2026-10-23 00:00"},
+		{"both day fields, day of month comes first", "0 0 1 * mon", "2026-10-27 00:00", "2026-11-01 00:00"},
+		{"weekdays skip the weekend", "0 9 * * mon-fri", "2026-10-23 10:00", "2026-10-26 09:00"},
+		{"seven is sunday", "0 0 * * 7", "2026-10-17 00:00", "2026-10-18 00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			got := s.Next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) for %q = %s, want no match", tt.from, tt.expr, got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", tt.from, tt.expr, got.Format("2006-01-02 15:04 Mon"), want.Format("2006-01-02 15:04 Mon"))
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionStart   Action = "start"
+	ActionStop    Action = "stop"
+	ActionRestart Action = "restart"
+	ActionStdin   Action = "stdin" // write Command to the instance's stdin
+	ActionHook    Action = "hook"  // run Command as a shell script in the instance directory
+)
+
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionStart, ActionStop, ActionRestart, ActionStdin, ActionHook:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown action %q (want start, stop, restart, stdin or hook)", s)
+}
+
+// Entry is one scheduled task of an instance.
+type Entry struct {
+	Instance string
+	Name     string
+	Spec     *Spec
+	Action   Action
+	Command  string        // stdin text or hook script
+	Timeout  time.Duration // hook timeout (0 = default)
+}
+
+// Status is an entry plus its run history, as reported by List.
+type Status struct {
+	Instance string
+	Name     string
+	Cron     string
+	Action   Action
+	Command  string
+
+	Next         time.Time
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastResult   string // "ok", "error" or "skipped"
+	LastError    string
+}
+
+// RunFunc executes an entry's action.
+type RunFunc func(ctx context.Context, e Entry) error
+
+type job struct {
+	Status
+	entry Entry
+}
+
+// Scheduler fires entries at their cron times. A run that is still in
+// progress when the entry fires again is skipped rather than overlapped.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string][]*job // instance -> jobs
+	run  RunFunc
+	wake chan struct{}
+	ctx  context.Context
+}
+
+func New(run RunFunc) *Scheduler {
+	return &Scheduler{
+		jobs: map[string][]*job{},
+		run:  run,
+		wake: make(chan struct{}, 1),
+		ctx:  context.Background(),
+	}
+}
+
+// Set replaces the entries of an instance. Run history is kept for entries
+// whose name did not change.
+func (s *Scheduler) Set(instance string, entries []Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := map[string]*job{}
+	for _, j := range s.jobs[instance] {
+		old[j.Name] = j
+	}
+
+	now := time.Now()
+	jobs := make([]*job, 0, len(entries))
+	for _, e := range entries {
+		e.Instance = instance
+		j := &job{entry: e}
+		if prev, ok := old[e.Name]; ok {
+			j.Status = prev.Status
+		}
+		j.Instance = instance
+		j.Name = e.Name
+		j.Cron = e.Spec.String()
+		j.Action = e.Action
+		j.Command = e.Command
+		j.Next = e.Spec.Next(now)
+		jobs = append(jobs, j)
+	}
+
+	if len(jobs) == 0 {
+		delete(s.jobs, instance)
+	} else {
+		s.jobs[instance] = jobs
+	}
+	s.poke()
+}
+
+func (s *Scheduler) Remove(instance string) {
+	s.Set(instance, nil)
+}
+
+// List returns the schedules of one instance, or of all instances when
+// instance is empty.
+func (s *Scheduler) List(instance string) []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []Status{}
+	for name, jobs := range s.jobs {
+		if instance != "" && name != instance {
+			continue
+		}
+		for _, j := range jobs {
+			out = append(out, j.Status)
+		}
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Instance != out[k].Instance {
+			return out[i].Instance < out[k].Instance
+		}
+		return out[i].Name < out[k].Name
+	})
+	return out
+}
+
+// Run fires due entries until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+
+		next := s.fireDue(time.Now())
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next.IsZero() {
+			timer.Reset(time.Hour)
+		} else {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// fireDue starts every due job and returns the earliest next fire time.
+func (s *Scheduler) fireDue(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, jobs := range s.jobs {
+		for _, j := range jobs {
+			if !j.Next.IsZero() && !j.Next.After(now) {
+				if j.Running {
+					j.LastRun = now
+					j.LastResult = "skipped"
+					j.LastError = "previous run still in progress"
+				} else {
+					j.Running = true
+					go s.execute(j)
+				}
+				j.Next = j.entry.Spec.Next(now)
+			}
+			if !j.Next.IsZero() && (earliest.IsZero() || j.Next.Before(earliest)) {
+				earliest = j.Next
+			}
+		}
+	}
+	return earliest
+}
+
+func (s *Scheduler) execute(j *job) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	started := time.Now()
+	err := s.run(ctx, j.entry)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.Running = false
+	j.LastRun = started
+	j.LastDuration = time.Since(started)
+	if err != nil {
+		j.LastResult = "error"
+		j.LastError = err.Error()
+		log.Printf("[agent] schedule %s/%s (%s) failed: %v", j.Instance, j.Name, j.Action, err)
+		return
+	}
+	j.LastResult = "ok"
+	j.LastError = ""
+}
+
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
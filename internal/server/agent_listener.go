@@ -61,7 +61,7 @@ func (l *AgentListener) handleConn(c net.Conn) {
 		return
 	}
 
-	l.registry.RegisterAgent(first.AgentID, reg.Servers, tc)
+	l.registry.RegisterAgent(first.AgentID, reg, tc)
 	log.Printf("[command-server] agent registered: %s servers=%v", first.AgentID, reg.Servers)
 
 	// Main loop reads responses from agent
@@ -80,7 +80,7 @@ func (l *AgentListener) handleConn(c net.Conn) {
 		if msg.Kind == protocol.KindRegister && msg.AgentID != "" {
 			var reg protocol.RegisterPayload
 			if err := json.Unmarshal(msg.Payload, &reg); err == nil {
-				l.registry.UpdateAgentServers(msg.AgentID, reg.Servers)
+				l.registry.UpdateAgent(msg.AgentID, reg)
 				log.Printf("[command-server] agent updated registration: %s servers=%v", msg.AgentID, reg.Servers)
 			}
 			continue
@@ -3,7 +3,10 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/faradayfan/remote-process-manager/internal/protocol"
@@ -36,10 +39,25 @@ func (s *HTTPServer) Handler() http.Handler {
 	// Commands to agents (relay)
 	mux.HandleFunc("POST /agents/{agentID}/servers/{server}/start", s.handleStart)
 	mux.HandleFunc("POST /agents/{agentID}/servers/{server}/stop", s.handleStop)
+	mux.HandleFunc("POST /agents/{agentID}/servers/{server}/restart", s.handleRestart)
+	mux.HandleFunc("POST /agents/{agentID}/restart", s.handleRollingRestart)
+	mux.HandleFunc("POST /agents/{agentID}/groups/{group}/start", s.handleGroupStart)
+	mux.HandleFunc("POST /agents/{agentID}/groups/{group}/stop", s.handleGroupStop)
 	mux.HandleFunc("GET /agents/{agentID}/servers/{server}/status", s.handleStatus)
+	mux.HandleFunc("GET /agents/{agentID}/servers/{server}/metrics", s.handleMetrics)
+	mux.HandleFunc("GET /agents/{agentID}/servers/{server}/logs", s.handleLogs)
+	mux.HandleFunc("POST /agents/{agentID}/servers/{server}/console", s.handleConsole)
 	mux.HandleFunc("GET /agents/{agentID}/instances", s.handleInstancesList)
 	mux.HandleFunc("POST /agents/{agentID}/instances/create", s.handleInstancesCreate)
 	mux.HandleFunc("POST /agents/{agentID}/instances/delete", s.handleInstancesDelete)
+	mux.HandleFunc("GET /agents/{agentID}/instances/{name}/history", s.handleInstanceHistory)
+	mux.HandleFunc("GET /agents/{agentID}/schedules", s.handleSchedules)
+	mux.HandleFunc("GET /agents/{agentID}/templates", s.handleTemplates)
+	mux.HandleFunc("GET /agents/{agentID}/templates/{template}", s.handleTemplates)
+	mux.HandleFunc("POST /agents/{agentID}/cordon", s.handleCordon)
+	mux.HandleFunc("POST /agents/{agentID}/uncordon", s.handleUncordon)
+	mux.HandleFunc("POST /agents/{agentID}/drain", s.handleDrain)
+	mux.HandleFunc("GET /agents/{agentID}/maintenance", s.handleMaintenance)
 
 	// Health
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -84,10 +102,18 @@ func (s *HTTPServer) handleStop(w http.ResponseWriter, r *http.Request) {
 	s.command(w, r, protocol.CmdStop)
 }
 
+func (s *HTTPServer) handleRestart(w http.ResponseWriter, r *http.Request) {
+	s.command(w, r, protocol.CmdRestart)
+}
+
 func (s *HTTPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	s.command(w, r, protocol.CmdStatus)
 }
 
+func (s *HTTPServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.command(w, r, protocol.CmdMetrics)
+}
+
 func (s *HTTPServer) command(w http.ResponseWriter, r *http.Request, cmdType string) {
 	agentID := r.PathValue("agentID")
 	serverName := r.PathValue("server")
@@ -101,7 +127,7 @@ func (s *HTTPServer) command(w http.ResponseWriter, r *http.Request, cmdType str
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout(cmdType))
 	defer cancel()
 
 	resp, err := s.registry.SendCommand(ctx, agentID, cmdType, protocol.ServerTarget{
@@ -138,6 +164,137 @@ func (s *HTTPServer) command(w http.ResponseWriter, r *http.Request, cmdType str
 	_, _ = w.Write(resp.Payload)
 }
 
+// commandTimeout allows for stop chains and pre_start hooks, which can take
+// much longer than a status lookup.
+func commandTimeout(cmdType string) time.Duration {
+	switch cmdType {
+	case protocol.CmdStop, protocol.CmdStart, protocol.CmdRestart:
+		return 5 * time.Minute
+	}
+	return 10 * time.Second
+}
+
+// handleRollingRestart restarts instances one at a time; the response reports
+// each instance's outcome.
+func (s *HTTPServer) handleRollingRestart(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+
+	var req protocol.RollingRestartRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	// Bounded by the client; each instance is bounded by the agent
+	resp, err := s.registry.SendCommand(r.Context(), agentID, protocol.CmdRestartRolling, req)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	var out protocol.RollingRestartResponse
+	status := http.StatusOK
+	if err := json.Unmarshal(resp.Payload, &out); err == nil && !out.OK {
+		status = http.StatusConflict
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(resp.Payload)
+}
+
+func (s *HTTPServer) handleGroupStart(w http.ResponseWriter, r *http.Request) {
+	s.groupCommand(w, r, protocol.CmdGroupStart)
+}
+
+func (s *HTTPServer) handleGroupStop(w http.ResponseWriter, r *http.Request) {
+	s.groupCommand(w, r, protocol.CmdGroupStop)
+}
+
+// groupCommand starts or stops a group; like a rolling restart, the response
+// reports each instance's outcome.
+func (s *HTTPServer) groupCommand(w http.ResponseWriter, r *http.Request, cmdType string) {
+	agentID := r.PathValue("agentID")
+	group := r.PathValue("group")
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+	if group == "" {
+		writeErr(w, http.StatusBadRequest, "missing group")
+		return
+	}
+
+	// Bounded by the client; each instance is bounded by the agent
+	resp, err := s.registry.SendCommand(r.Context(), agentID, cmdType, protocol.GroupRequest{Group: group})
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	var out protocol.GroupResponse
+	status := http.StatusOK
+	if err := json.Unmarshal(resp.Payload, &out); err == nil && !out.OK {
+		status = http.StatusConflict
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(resp.Payload)
+}
+
+func (s *HTTPServer) handleConsole(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	serverName := r.PathValue("server")
+
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+	if serverName == "" {
+		writeErr(w, http.StatusBadRequest, "missing server name")
+		return
+	}
+
+	var req protocol.ConsoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	req.Server = serverName
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := s.registry.SendCommand(ctx, agentID, protocol.CmdConsoleSend, req)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.Payload)
+}
+
 func (s *HTTPServer) handleInstancesList(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("agentID")
 	if agentID == "" {
@@ -163,6 +320,159 @@ func (s *HTTPServer) handleInstancesList(w http.ResponseWriter, r *http.Request)
 	_, _ = w.Write(resp.Payload)
 }
 
+// handleInstanceHistory returns past runs, newest first; ?limit= caps them.
+func (s *HTTPServer) handleInstanceHistory(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	name := r.PathValue("name")
+	if agentID == "" || name == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID or instance name")
+		return
+	}
+
+	req := protocol.InstanceHistoryRequest{Name: name}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErr(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		req.Limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := s.registry.SendCommand(ctx, agentID, protocol.CmdInstancesHistory, req)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.Payload)
+}
+
+// handleSchedules lists scheduled tasks; ?instance= limits them to one instance.
+func (s *HTTPServer) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := s.registry.SendCommand(ctx, agentID, protocol.CmdSchedulesList, protocol.SchedulesRequest{
+		Instance: r.URL.Query().Get("instance"),
+	})
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.Payload)
+}
+
+// handleTemplates lists an agent's templates with their params, or
+// describes one of them.
+func (s *HTTPServer) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cmdType, payload := protocol.CmdTemplatesList, any(nil)
+	if tpl := r.PathValue("template"); tpl != "" {
+		cmdType, payload = protocol.CmdTemplatesDescribe, protocol.TemplateRequest{Template: tpl}
+	}
+	resp, err := s.registry.SendCommand(ctx, agentID, cmdType, payload)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.Payload)
+}
+
+func (s *HTTPServer) handleCordon(w http.ResponseWriter, r *http.Request) {
+	s.maintenanceCommand(w, r, protocol.CmdCordon)
+}
+
+func (s *HTTPServer) handleUncordon(w http.ResponseWriter, r *http.Request) {
+	s.maintenanceCommand(w, r, protocol.CmdUncordon)
+}
+
+// handleDrain returns as soon as the drain has begun; poll
+// GET /agents/{agentID}/maintenance for its progress.
+func (s *HTTPServer) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.maintenanceCommand(w, r, protocol.CmdDrain)
+}
+
+func (s *HTTPServer) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	s.maintenanceCommand(w, r, protocol.CmdMaintenance)
+}
+
+// maintenanceCommand relays a cordon, uncordon, drain or maintenance status
+// request; the response is the agent's maintenance status.
+func (s *HTTPServer) maintenanceCommand(w http.ResponseWriter, r *http.Request, cmdType string) {
+	agentID := r.PathValue("agentID")
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+
+	var req protocol.CordonRequest
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := s.registry.SendCommand(ctx, agentID, cmdType, req)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	status := http.StatusOK
+	if cmdType == protocol.CmdDrain {
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(resp.Payload)
+}
+
 func (s *HTTPServer) handleInstancesCreate(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("agentID")
 	if agentID == "" {
@@ -224,3 +534,107 @@ func (s *HTTPServer) handleInstancesDelete(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(resp.Payload)
 }
+
+// handleLogs writes the tail of an instance log as text/plain. With
+// ?follow=true the response stays open and new output is streamed (chunked)
+// until the client goes away or the agent ends the stream.
+func (s *HTTPServer) handleLogs(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentID")
+	serverName := r.PathValue("server")
+
+	if agentID == "" {
+		writeErr(w, http.StatusBadRequest, "missing agentID")
+		return
+	}
+	if serverName == "" {
+		writeErr(w, http.StatusBadRequest, "missing server name")
+		return
+	}
+
+	req := protocol.LogsRequest{Server: serverName, Tail: 100}
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErr(w, http.StatusBadRequest, "invalid tail")
+			return
+		}
+		req.Tail = n
+	}
+	if v := r.URL.Query().Get("follow"); v != "" {
+		follow, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid follow")
+			return
+		}
+		req.Follow = follow
+	}
+
+	var resp protocol.Message
+	var chunks <-chan protocol.Message
+	var err error
+	if req.Follow {
+		// Stream lives as long as the HTTP request
+		resp, chunks, err = s.registry.Stream(r.Context(), agentID, protocol.CmdLogs, req, protocol.CmdLogsUnsubscribe)
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		resp, err = s.registry.SendCommand(ctx, agentID, protocol.CmdLogs, req)
+	}
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resp.Error != "" {
+		writeErr(w, http.StatusBadRequest, resp.Error)
+		return
+	}
+
+	var tail protocol.LogsResponse
+	if err := json.Unmarshal(resp.Payload, &tail); err != nil {
+		writeErr(w, http.StatusBadGateway, "invalid agent response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if len(tail.Lines) > 0 {
+		_, _ = w.Write([]byte(strings.Join(tail.Lines, "\n") + "\n"))
+	}
+	if chunks == nil {
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	// The status is already sent, so a stream that ends early says so in the body
+	for msg := range chunks {
+		if msg.Error != "" {
+			_, _ = fmt.Fprintf(w, "\n[log stream truncated: %s]\n", msg.Error)
+			return
+		}
+		var chunk protocol.LogChunk
+		if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+			continue
+		}
+		if chunk.Data != "" {
+			if _, err := w.Write([]byte(chunk.Data)); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if chunk.EOF {
+			if chunk.Error != "" {
+				_, _ = fmt.Fprintf(w, "\n[log stream ended: %s]\n", chunk.Error)
+			}
+			return
+		}
+	}
+	if r.Context().Err() == nil {
+		_, _ = fmt.Fprintf(w, "\n[log stream ended: agent disconnected]\n")
+	}
+}
@@ -17,6 +17,13 @@ type AgentInfo struct {
 	Servers     []string  `json:"servers"`
 	ConnectedAt time.Time `json:"connected_at"`
 	LastSeen    time.Time `json:"last_seen"`
+
+	// Autostart outcomes reported by the agent since it booted
+	Autostart []protocol.AutostartResult `json:"autostart,omitempty"`
+
+	// Set while the agent is cordoned for maintenance
+	Cordoned     bool   `json:"cordoned,omitempty"`
+	CordonReason string `json:"cordon_reason,omitempty"`
 }
 
 type agentConn struct {
@@ -25,8 +32,19 @@ type agentConn struct {
 
 	mu      sync.Mutex
 	pending map[string]chan protocol.Message // request id -> response channel
+	streams map[string]*stream               // request id -> stream
 }
 
+// stream is the consumer side of a streaming request.
+type stream struct {
+	ch          chan protocol.Message
+	unsubscribe string // request type that asks the agent to stop
+}
+
+// ErrStreamOverflow is the Error of the last message of a stream whose
+// consumer fell too far behind; the messages after it were dropped.
+const ErrStreamOverflow = "reader fell behind; output was dropped"
+
 type Registry struct {
 	mu     sync.Mutex
 	agents map[string]*agentConn
@@ -59,23 +77,27 @@ func (r *Registry) GetAgent(agentID string) (AgentInfo, bool) {
 	return a.info, true
 }
 
-func (r *Registry) RegisterAgent(agentID string, servers []string, c *transport.Conn) {
+func (r *Registry) RegisterAgent(agentID string, reg protocol.RegisterPayload, c *transport.Conn) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.agents[agentID] = &agentConn{
 		info: AgentInfo{
-			AgentID:     agentID,
-			Servers:     servers,
-			ConnectedAt: time.Now().UTC(),
-			LastSeen:    time.Now().UTC(),
+			AgentID:      agentID,
+			Servers:      reg.Servers,
+			Autostart:    reg.Autostart,
+			Cordoned:     reg.Cordoned,
+			CordonReason: reg.CordonReason,
+			ConnectedAt:  time.Now().UTC(),
+			LastSeen:     time.Now().UTC(),
 		},
 		conn:    c,
 		pending: map[string]chan protocol.Message{},
+		streams: map[string]*stream{},
 	}
 }
 
-func (r *Registry) UpdateAgentServers(agentID string, servers []string) {
+func (r *Registry) UpdateAgent(agentID string, reg protocol.RegisterPayload) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -83,14 +105,22 @@ func (r *Registry) UpdateAgentServers(agentID string, servers []string) {
 	if !ok {
 		return
 	}
-	a.info.Servers = servers
+	a.info.Servers = reg.Servers
+	a.info.Autostart = reg.Autostart
+	a.info.Cordoned = reg.Cordoned
+	a.info.CordonReason = reg.CordonReason
 	a.info.LastSeen = time.Now().UTC()
 }
 
 func (r *Registry) RemoveAgent(agentID string) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	a, ok := r.agents[agentID]
 	delete(r.agents, agentID)
+	r.mu.Unlock()
+
+	if ok {
+		a.closeStreams()
+	}
 }
 
 func (r *Registry) Touch(agentID string) {
@@ -140,6 +170,112 @@ func (r *Registry) SendCommand(ctx context.Context, agentID string, typ string,
 	}
 }
 
+// streamResponseTimeout bounds the wait for the response that opens a
+// stream; the stream itself lives as long as the caller's ctx.
+const streamResponseTimeout = 10 * time.Second
+
+// Stream sends a request whose response is followed by KindStream messages
+// (e.g. CmdLogs with Follow). It returns the initial response and a channel
+// of stream messages that is closed when the agent ends the stream, the
+// agent disconnects, the consumer falls too far behind (the last message then
+// carries ErrStreamOverflow), or ctx is done. Cancelling ctx or falling
+// behind also asks the agent to stop via unsubscribeType.
+func (r *Registry) Stream(ctx context.Context, agentID string, typ string, payload any, unsubscribeType string) (protocol.Message, <-chan protocol.Message, error) {
+	a := r.get(agentID)
+	if a == nil {
+		return protocol.Message{}, nil, fmt.Errorf("agent not connected: %s", agentID)
+	}
+
+	reqID := newID()
+
+	req, err := protocol.NewRequest(agentID, reqID, typ, payload)
+	if err != nil {
+		return protocol.Message{}, nil, err
+	}
+
+	respCh := make(chan protocol.Message, 1)
+	streamCh := make(chan protocol.Message, 256)
+
+	// Register the stream before sending so no early chunk is dropped
+	a.mu.Lock()
+	a.pending[reqID] = respCh
+	a.streams[reqID] = &stream{ch: streamCh, unsubscribe: unsubscribeType}
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, reqID)
+		a.mu.Unlock()
+	}()
+
+	if err := a.conn.Send(req); err != nil {
+		a.closeStream(reqID)
+		return protocol.Message{}, nil, err
+	}
+
+	timer := time.NewTimer(streamResponseTimeout)
+	defer timer.Stop()
+
+	// The agent may start streaming later all the same, so it is told to stop
+	var resp protocol.Message
+	select {
+	case <-ctx.Done():
+		a.closeStream(reqID)
+		go a.unsubscribe(reqID, unsubscribeType)
+		return protocol.Message{}, nil, fmt.Errorf("waiting for agent response: %w", ctx.Err())
+	case <-timer.C:
+		a.closeStream(reqID)
+		go a.unsubscribe(reqID, unsubscribeType)
+		return protocol.Message{}, nil, fmt.Errorf("timeout waiting for agent response")
+	case resp = <-respCh:
+	}
+
+	if resp.Error != "" {
+		a.closeStream(reqID)
+		return resp, nil, nil
+	}
+
+	go func() {
+		<-ctx.Done()
+		if a.closeStream(reqID) {
+			a.unsubscribe(reqID, unsubscribeType)
+		}
+	}()
+
+	return resp, streamCh, nil
+}
+
+// closeStream removes and closes a stream channel; it reports whether the
+// stream was still open.
+func (a *agentConn) closeStream(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.streams[id]
+	if ok {
+		delete(a.streams, id)
+		close(st.ch)
+	}
+	return ok
+}
+
+func (a *agentConn) closeStreams() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, st := range a.streams {
+		delete(a.streams, id)
+		close(st.ch)
+	}
+}
+
+// unsubscribe asks the agent to end stream id. Fire and forget; the response
+// is dropped as nobody is pending on it.
+func (a *agentConn) unsubscribe(id string, typ string) {
+	unsub, err := protocol.NewRequest(a.info.AgentID, newID(), typ, protocol.UnsubscribeRequest{ID: id})
+	if err == nil {
+		_ = a.conn.Send(unsub)
+	}
+}
+
 func (r *Registry) HandleIncomingFromAgent(msg protocol.Message) {
 	// Dispatch response -> pending channel
 	r.Touch(msg.AgentID)
@@ -149,6 +285,11 @@ func (r *Registry) HandleIncomingFromAgent(msg protocol.Message) {
 		return
 	}
 
+	if msg.Kind == protocol.KindStream {
+		a.dispatchStream(msg)
+		return
+	}
+
 	if msg.Kind != protocol.KindResponse {
 		return
 	}
@@ -173,3 +314,26 @@ func newID() string {
 	_, _ = rand.Read(b[:])
 	return hex.EncodeToString(b[:])
 }
+
+// dispatchStream hands a stream message to its consumer without ever blocking
+// the agent read loop: a consumer that falls behind loses its stream. The last
+// buffer slot is kept for the message telling it so.
+func (a *agentConn) dispatchStream(msg protocol.Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.streams[msg.ID]
+	if !ok {
+		return
+	}
+	if len(st.ch) < cap(st.ch)-1 {
+		st.ch <- msg
+		return
+	}
+
+	st.ch <- protocol.Message{Kind: protocol.KindStream, ID: msg.ID, AgentID: msg.AgentID, Error: ErrStreamOverflow, TS: time.Now().UTC()}
+	delete(a.streams, msg.ID)
+	close(st.ch)
+	// Not under a.mu: sending can block on the connection
+	go a.unsubscribe(msg.ID, st.unsubscribe)
+}
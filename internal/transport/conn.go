@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/faradayfan/remote-process-manager/internal/protocol"
@@ -14,7 +15,10 @@ import (
 type Conn struct {
 	c net.Conn
 	r *bufio.Reader
-	w *bufio.Writer
+
+	// Send is called from several goroutines (responses, heartbeats, streams)
+	wmu sync.Mutex
+	w   *bufio.Writer
 }
 
 func NewConn(c net.Conn) *Conn {
@@ -39,6 +43,9 @@ func (c *Conn) Send(msg protocol.Message) error {
 		return err
 	}
 
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
 	// newline framed
 	if _, err := c.w.Write(append(b, '\n')); err != nil {
 		return err